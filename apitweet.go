@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// apiTweet adapts a tweet from the API to a tweetRecord
+type apiTweet struct {
+	twitter.Tweet
+}
+
+func (t apiTweet) tweetID() int64                { return t.ID }
+func (t apiTweet) createdAt() (time.Time, error) { return t.CreatedAtTime() }
+func (t apiTweet) text() string                  { return t.Text }
+func (t apiTweet) lang() string                  { return t.Lang }
+func (t apiTweet) geotagged() bool               { return t.Place != nil || t.Coordinates != nil }
+func (t apiTweet) sensitive() bool               { return t.PossiblySensitive }
+func (t apiTweet) favoriteCount() int            { return t.FavoriteCount }
+func (t apiTweet) retweetCount() int             { return t.RetweetCount }
+
+func (t apiTweet) replyTo() (int64, string) {
+	return t.InReplyToUserID, t.InReplyToScreenName
+}
+
+func (t apiTweet) links() []link {
+	if t.Entities == nil {
+		return nil
+	}
+	var links []link
+	for _, u := range t.Entities.Urls {
+		links = append(links, link{url: u.URL, expandedURL: u.ExpandedURL})
+	}
+	return links
+}
+
+func (t apiTweet) mentions() []string {
+	if t.Entities == nil {
+		return nil
+	}
+	var names []string
+	for _, mention := range t.Entities.UserMentions {
+		names = append(names, mention.ScreenName)
+	}
+	return names
+}
+
+func (t apiTweet) hashtags() []string {
+	if t.Entities == nil {
+		return nil
+	}
+	var tags []string
+	for _, h := range t.Entities.Hashtags {
+		tags = append(tags, h.Text)
+	}
+	return tags
+}
+
+// quotedUserID needs the quoted tweet's user, which is why -keep-self-quotes
+// fetches without trim_user
+func (t apiTweet) quotedUserID() int64 {
+	if t.QuotedStatus == nil || t.QuotedStatus.User == nil {
+		return 0
+	}
+	return t.QuotedStatus.User.ID
+}
+
+func (t apiTweet) textOnly() bool {
+	if t.QuotedStatusID != 0 || t.QuotedStatus != nil || t.hasMedia() {
+		return false
+	}
+	if t.Entities != nil && len(t.Entities.Urls) > 0 {
+		return false
+	}
+	return true
+}
+
+// hasMedia checks the extended entities too, since they list every photo
+// where the entities only list the first
+func (t apiTweet) hasMedia() bool {
+	if t.ExtendedEntities != nil && len(t.ExtendedEntities.Media) > 0 {
+		return true
+	}
+	return t.Entities != nil && len(t.Entities.Media) > 0
+}
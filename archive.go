@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// archiveFilePatterns are the names tweets have been exported under in
+// Twitter's data archives over the years, relative to the archive or its data
+// directory. Large archives are split into parts. The oldest, Grailbird,
+// archives have a file per month, named like 2015_01.js.
+var archiveFilePatterns = []string{
+	"tweets.js",
+	"tweet.js",
+	"tweets-part*.js",
+	"tweet-part*.js",
+	filepath.Join("js", "tweets", "[0-9][0-9][0-9][0-9]_[0-9][0-9].js"),
+}
+
+// archiveFiles returns the tweet files in an archive. path is either a tweets
+// file or an extracted archive directory.
+func archiveFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, dir := range []string{path, filepath.Join(path, "data")} {
+		for _, pattern := range archiveFilePatterns {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no tweets file found in %s", path)
+	}
+	return files, nil
+}
+
+// maxStatusLookup is the most tweets a single statuses/lookup request returns
+const maxStatusLookup = 100
+
+// lookupExisting returns the current version of each of the archived tweets
+// that still exist, looked up with statuses/lookup up to maxStatusLookup at a
+// time. Tweets that were deleted, or that the account can no longer see, are
+// left out. When trimUser is set, each tweet's user is trimmed down to its ID.
+func lookupExisting(client *twitter.Client, tweets []archiveTweet, trimUser bool) (map[int64]twitter.Tweet, error) {
+	existing := map[int64]twitter.Tweet{}
+	for len(tweets) > 0 {
+		batch := tweets
+		if len(batch) > maxStatusLookup {
+			batch = batch[:maxStatusLookup]
+		}
+		tweets = tweets[len(batch):]
+
+		ids := make([]int64, len(batch))
+		for i, t := range batch {
+			ids[i] = t.tweetID()
+		}
+		var found []twitter.Tweet
+		_, err := call(func() (resp *http.Response, err error) {
+			found, resp, err = client.Statuses.Lookup(ids, &twitter.StatusLookupParams{TrimUser: &trimUser})
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up tweets: %w", err)
+		}
+		for _, t := range found {
+			existing[t.ID] = t
+		}
+	}
+	return existing, nil
+}
+
+// readArchive reads the tweets in a Twitter data archive, newest first
+func readArchive(path string) ([]archiveTweet, error) {
+	files, err := archiveFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var tweets []archiveTweet
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseArchiveTweets(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		tweets = append(tweets, parsed...)
+	}
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].ID > tweets[j].ID
+	})
+	return tweets, nil
+}
+
+// parseArchiveTweets parses the contents of an archive's tweets file. The
+// files are JavaScript assigning an array to a variable, such as
+// "window.YTD.tweet.part0 = [...]" or, in older archives,
+// "Grailbird.data.tweets_2015_01 = [...]". Newer archives wrap each tweet in
+// an object under "tweet"; older ones don't.
+func parseArchiveTweets(b []byte) ([]archiveTweet, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("[")) {
+		i := bytes.IndexByte(b, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("expected a JSON array or an assignment of one")
+		}
+		b = b[i+1:]
+	}
+
+	var entries []struct {
+		Tweet *archiveTweet `json:"tweet"`
+		archiveTweet
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	tweets := make([]archiveTweet, 0, len(entries))
+	for _, e := range entries {
+		at := e.archiveTweet
+		if e.Tweet != nil {
+			at = *e.Tweet
+		}
+		if at.ID == 0 {
+			return nil, fmt.Errorf("tweet without an ID")
+		}
+		if _, err := at.createdAt(); err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, at)
+	}
+	return tweets, nil
+}
+
+// archiveTweet is a tweet as exported in an archive. IDs and counts are
+// strings in newer archives and numbers in older ones. It's a tweetRecord, so
+// the retention rules judge archived tweets by what the archive says.
+type archiveTweet struct {
+	ID                  archiveInt       `json:"id_str"`
+	CreatedAt           string           `json:"created_at"`
+	FullText            string           `json:"full_text"`
+	Text                string           `json:"text"`
+	Lang                string           `json:"lang"`
+	FavoriteCount       archiveInt       `json:"favorite_count"`
+	RetweetCount        archiveInt       `json:"retweet_count"`
+	InReplyToStatusID   archiveInt       `json:"in_reply_to_status_id_str"`
+	InReplyToUserID     archiveInt       `json:"in_reply_to_user_id_str"`
+	InReplyToScreenName string           `json:"in_reply_to_screen_name"`
+	PossiblySensitive   bool             `json:"possibly_sensitive"`
+	Coordinates         json.RawMessage  `json:"coordinates"`
+	Place               json.RawMessage  `json:"place"`
+	Entities            *archiveEntities `json:"entities"`
+	ExtendedEntities    *archiveEntities `json:"extended_entities"`
+}
+
+// archiveEntities are the entities of an archived tweet. Their indices are
+// strings, which go-twitter's entity types can't decode.
+type archiveEntities struct {
+	Hashtags []struct {
+		Text string `json:"text"`
+	} `json:"hashtags"`
+	UserMentions []struct {
+		ID         archiveInt `json:"id_str"`
+		ScreenName string     `json:"screen_name"`
+	} `json:"user_mentions"`
+	Urls []struct {
+		URL         string `json:"url"`
+		ExpandedURL string `json:"expanded_url"`
+	} `json:"urls"`
+	Media []struct {
+		ID   archiveInt `json:"id_str"`
+		Type string     `json:"type"`
+	} `json:"media"`
+}
+
+// archiveCreatedAtLayouts are the formats of created_at across archive eras.
+// The first is the API's.
+var archiveCreatedAtLayouts = []string{
+	time.RubyDate,
+	"2006-01-02 15:04:05 -0700",
+}
+
+func (at archiveTweet) tweetID() int64     { return int64(at.ID) }
+func (at archiveTweet) lang() string       { return at.Lang }
+func (at archiveTweet) sensitive() bool    { return at.PossiblySensitive }
+func (at archiveTweet) favoriteCount() int { return int(at.FavoriteCount) }
+func (at archiveTweet) retweetCount() int  { return int(at.RetweetCount) }
+
+func (at archiveTweet) createdAt() (time.Time, error) {
+	for _, layout := range archiveCreatedAtLayouts {
+		if createdAt, err := time.Parse(layout, at.CreatedAt); err == nil {
+			return createdAt, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("tweet %d has an unknown created_at format %q", at.ID, at.CreatedAt)
+}
+
+// text is the full text, which older archives, from before the 280 character
+// limit, don't have
+func (at archiveTweet) text() string {
+	if at.FullText != "" {
+		return at.FullText
+	}
+	return at.Text
+}
+
+func (at archiveTweet) replyTo() (int64, string) {
+	return int64(at.InReplyToUserID), at.InReplyToScreenName
+}
+
+func (at archiveTweet) geotagged() bool {
+	return isJSONValue(at.Coordinates) || isJSONValue(at.Place)
+}
+
+func (at archiveTweet) links() []link {
+	if at.Entities == nil {
+		return nil
+	}
+	var links []link
+	for _, u := range at.Entities.Urls {
+		links = append(links, link{url: u.URL, expandedURL: u.ExpandedURL})
+	}
+	return links
+}
+
+func (at archiveTweet) mentions() []string {
+	if at.Entities == nil {
+		return nil
+	}
+	var names []string
+	for _, m := range at.Entities.UserMentions {
+		names = append(names, m.ScreenName)
+	}
+	return names
+}
+
+func (at archiveTweet) hashtags() []string {
+	if at.Entities == nil {
+		return nil
+	}
+	var tags []string
+	for _, h := range at.Entities.Hashtags {
+		tags = append(tags, h.Text)
+	}
+	return tags
+}
+
+// quotedUserID is never known, since archives don't include quoted tweets
+func (at archiveTweet) quotedUserID() int64 { return 0 }
+
+// textOnly treats any link as disqualifying, which covers quoted tweets:
+// archives only have them as a link to the quoted tweet
+func (at archiveTweet) textOnly() bool {
+	return !at.hasMedia() && (at.Entities == nil || len(at.Entities.Urls) == 0)
+}
+
+func (at archiveTweet) hasMedia() bool {
+	if at.ExtendedEntities != nil && len(at.ExtendedEntities.Media) > 0 {
+		return true
+	}
+	return at.Entities != nil && len(at.Entities.Media) > 0
+}
+
+// toTweet converts an archived tweet to the API's representation, for
+// deleting it and recording it in backups and reports. Fields the archive
+// doesn't have are left empty.
+func (at archiveTweet) toTweet() (twitter.Tweet, error) {
+	createdAt, err := at.createdAt()
+	if err != nil {
+		return twitter.Tweet{}, err
+	}
+	text := at.text()
+	t := twitter.Tweet{
+		ID:                  int64(at.ID),
+		IDStr:               strconv.FormatInt(int64(at.ID), 10),
+		CreatedAt:           createdAt.Format(time.RubyDate),
+		Text:                text,
+		FullText:            text,
+		Lang:                at.Lang,
+		FavoriteCount:       int(at.FavoriteCount),
+		RetweetCount:        int(at.RetweetCount),
+		InReplyToStatusID:   int64(at.InReplyToStatusID),
+		InReplyToUserID:     int64(at.InReplyToUserID),
+		InReplyToScreenName: at.InReplyToScreenName,
+		PossiblySensitive:   at.PossiblySensitive,
+		Entities:            at.Entities.toEntities(),
+	}
+	if isJSONValue(at.Coordinates) {
+		t.Coordinates = &twitter.Coordinates{}
+	}
+	if isJSONValue(at.Place) {
+		t.Place = &twitter.Place{}
+	}
+	if at.ExtendedEntities != nil {
+		t.ExtendedEntities = &twitter.ExtendedEntity{Media: at.ExtendedEntities.toEntities().Media}
+	}
+	return t, nil
+}
+
+// toEntities converts archived entities to the API's representation, leaving
+// out indices.
+func (ae *archiveEntities) toEntities() *twitter.Entities {
+	if ae == nil {
+		return nil
+	}
+	e := &twitter.Entities{}
+	for _, h := range ae.Hashtags {
+		e.Hashtags = append(e.Hashtags, twitter.HashtagEntity{Text: h.Text})
+	}
+	for _, m := range ae.UserMentions {
+		e.UserMentions = append(e.UserMentions, twitter.MentionEntity{ID: int64(m.ID), ScreenName: m.ScreenName})
+	}
+	for _, u := range ae.Urls {
+		e.Urls = append(e.Urls, twitter.URLEntity{URL: u.URL, ExpandedURL: u.ExpandedURL})
+	}
+	for _, m := range ae.Media {
+		e.Media = append(e.Media, twitter.MediaEntity{ID: int64(m.ID), Type: m.Type})
+	}
+	return e
+}
+
+// isJSONValue reports whether raw holds a value other than null
+func isJSONValue(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
+
+// archiveInt is an integer that archives encode as either a string or a number
+type archiveInt int64
+
+func (n *archiveInt) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = archiveInt(v)
+	return nil
+}
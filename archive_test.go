@@ -0,0 +1,176 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArchiveTweetRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		js   string
+		want archiveRecordFields
+	}{
+		{
+			name: "wrapped with string IDs",
+			js: `window.YTD.tweet.part0 = [{"tweet": {
+				"id_str": "1100", "created_at": "Tue Mar 05 10:00:00 +0000 2019",
+				"full_text": "#go with @gopher https://t.co/x", "lang": "en",
+				"favorite_count": "3", "retweet_count": "1",
+				"in_reply_to_user_id_str": "7", "in_reply_to_screen_name": "someone",
+				"entities": {
+					"hashtags": [{"text": "go", "indices": ["0", "3"]}],
+					"user_mentions": [{"id_str": "9", "screen_name": "gopher", "indices": ["9", "16"]}],
+					"urls": [{"url": "https://t.co/x", "expanded_url": "https://go.dev", "indices": ["17", "31"]}]
+				}
+			}}]`,
+			want: archiveRecordFields{
+				id:        1100,
+				createdAt: time.Date(2019, 3, 5, 10, 0, 0, 0, time.UTC),
+				text:      "#go with @gopher https://t.co/x",
+				lang:      "en",
+				hashtags:  []string{"go"},
+				mentions:  []string{"gopher"},
+				links:     []link{{url: "https://t.co/x", expandedURL: "https://go.dev"}},
+				replyToID: 7, replyToName: "someone",
+				favorites: 3, retweets: 1,
+			},
+		},
+		{
+			name: "bare with numeric IDs",
+			js: `Grailbird.data.tweets_2012_06 = [{
+				"id": 500, "id_str": "500", "created_at": "2012-06-01 08:30:00 +0000",
+				"text": "lunch", "geo": {}, "coordinates": {"type": "Point"},
+				"entities": {"media": [{"id_str": "1", "type": "photo"}]}
+			}]`,
+			want: archiveRecordFields{
+				id:        500,
+				createdAt: time.Date(2012, 6, 1, 8, 30, 0, 0, time.UTC),
+				text:      "lunch",
+				geotagged: true,
+				hasMedia:  true,
+			},
+		},
+		{
+			name: "text only",
+			js: `[{"id_str": "42", "created_at": "Wed Jan 01 00:00:00 +0000 2020",
+				"full_text": "just words", "place": null, "coordinates": null}]`,
+			want: archiveRecordFields{
+				id:        42,
+				createdAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				text:      "just words",
+				textOnly:  true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweets, err := parseArchiveTweets([]byte(tt.js))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tweets) != 1 {
+				t.Fatalf("parsed %d tweets, want 1", len(tweets))
+			}
+			got, err := recordFields(tweets[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// archiveRecordFields is everything a tweetRecord says about a tweet
+type archiveRecordFields struct {
+	id                  int64
+	createdAt           time.Time
+	text, lang          string
+	hashtags, mentions  []string
+	links               []link
+	replyToID           int64
+	replyToName         string
+	geotagged           bool
+	sensitive           bool
+	textOnly, hasMedia  bool
+	favorites, retweets int
+}
+
+func recordFields(rec tweetRecord) (archiveRecordFields, error) {
+	createdAt, err := rec.createdAt()
+	if err != nil {
+		return archiveRecordFields{}, err
+	}
+	replyToID, replyToName := rec.replyTo()
+	return archiveRecordFields{
+		id:          rec.tweetID(),
+		createdAt:   createdAt.UTC(),
+		text:        rec.text(),
+		lang:        rec.lang(),
+		hashtags:    rec.hashtags(),
+		mentions:    rec.mentions(),
+		links:       rec.links(),
+		replyToID:   replyToID,
+		replyToName: replyToName,
+		geotagged:   rec.geotagged(),
+		sensitive:   rec.sensitive(),
+		textOnly:    rec.textOnly(),
+		hasMedia:    rec.hasMedia(),
+		favorites:   rec.favoriteCount(),
+		retweets:    rec.retweetCount(),
+	}, nil
+}
+
+func TestParseArchiveTweetsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		js   string
+	}{
+		{"not an array", `window.YTD.tweet.part0 = {}`},
+		{"no assignment", `hello`},
+		{"missing ID", `[{"created_at": "Wed Jan 01 00:00:00 +0000 2020"}]`},
+		{"unknown date format", `[{"id_str": "1", "created_at": "yesterday"}]`},
+	}
+	for _, tt := range tests {
+		if _, err := parseArchiveTweets([]byte(tt.js)); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantIDs []int64
+	}{
+		{"testdata/archive-2019", []int64{1102, 1101}},
+		{"testdata/archive-2019/data/tweet.js", []int64{1102, 1101}},
+		{"testdata/archive-2022", []int64{1204, 1203, 1201}},
+		{"testdata/archive-2022/data", []int64{1204, 1203, 1201}},
+		{"testdata/archive-grailbird", []int64{1003, 1002, 1001}},
+		{"testdata/archive-grailbird/data/js/tweets/2013_01.js", []int64{1002, 1001}},
+	}
+	for _, tt := range tests {
+		tweets, err := readArchive(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		var ids []int64
+		for _, at := range tweets {
+			ids = append(ids, at.tweetID())
+		}
+		if !reflect.DeepEqual(ids, tt.wantIDs) {
+			t.Errorf("%s: read %v, want %v", tt.path, ids, tt.wantIDs)
+		}
+	}
+}
+
+func TestReadArchiveWithoutTweets(t *testing.T) {
+	if _, err := readArchive("testdata"); err == nil {
+		t.Error("got no error for a directory without a tweets file")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// maxBackupLine is the longest line loadBackupIDs reads. Tweets with full user
+// objects run to a few kilobytes.
+const maxBackupLine = 1 << 20
+
+// backupFile appends tweets to a JSON Lines file before they're deleted.
+// Tweets already in the file, from an earlier or interrupted run, aren't
+// written again.
+type backupFile struct {
+	file *os.File
+	ids  map[int64]bool
+}
+
+// openBackupFile opens a backup file for appending, creating it if necessary
+func openBackupFile(path string) (*backupFile, error) {
+	ids, err := loadBackupIDs(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// A crash can leave the last line cut short; start a fresh line after it
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return &backupFile{
+		file: f,
+		ids:  ids,
+	}, nil
+}
+
+// loadBackupIDs returns the IDs of the tweets in a backup file. A missing file
+// has none. Lines that can't be decoded, such as one cut short by a crash, are
+// skipped so their tweets are backed up again.
+func loadBackupIDs(path string) (map[int64]bool, error) {
+	ids := map[int64]bool{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxBackupLine)
+	for scanner.Scan() {
+		var t struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil || t.ID == 0 {
+			continue
+		}
+		ids[t.ID] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// add writes a tweet to the file unless it's already there. Each tweet is
+// synced to disk before returning, since it's about to be deleted.
+func (f *backupFile) add(t twitter.Tweet) error {
+	if f.ids[t.ID] {
+		return nil
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+	f.ids[t.ID] = true
+	return nil
+}
+
+// Close closes the file
+func (f *backupFile) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultConfigPath returns where the config file is looked for when -config
+// isn't given: $XDG_CONFIG_HOME/tprune/config.yaml, falling back to
+// ~/.config/tprune/config.yaml.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "tprune", "config.yaml")
+}
+
+// applyConfigFile sets flags from a config file. Flags given on the command
+// line take precedence over the file. When path is empty the default path is
+// used, and a missing default file is not an error. Settings that don't match a
+// flag are an error when strict is set and ignored otherwise.
+//
+// The file is a flat YAML mapping of flag names to values:
+//
+//	max-age: 1440h
+//	keep-keywords: [pinned, thread]
+func applyConfigFile(flagset *flag.FlagSet, path string, strict bool) error {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return nil
+		}
+	}
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	set := map[string]bool{}
+	flagset.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, kv := range values {
+		if set[kv.key] {
+			continue
+		}
+		if flagset.Lookup(kv.key) == nil {
+			if !strict {
+				continue
+			}
+			return fmt.Errorf("%s:%d: unknown setting %q", path, kv.line, kv.key)
+		}
+		if err := flagset.Set(kv.key, kv.value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value for %q: %w", path, kv.line, kv.key, err)
+		}
+	}
+	return nil
+}
+
+// configValue is a single setting from a config file
+type configValue struct {
+	key, value string
+	line       int
+}
+
+// readConfigFile parses a flat YAML mapping of scalar values. Flow sequences
+// like [a, b] are joined with commas to match the list flags.
+func readConfigFile(path string) ([]configValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		values  []configValue
+		scanner = bufio.NewScanner(f)
+		line    int
+	)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		i := strings.Index(text, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, line)
+		}
+		values = append(values, configValue{
+			key:   strings.TrimPrefix(strings.TrimSpace(text[:i]), "-"),
+			value: parseConfigScalar(strings.TrimSpace(text[i+1:])),
+			line:  line,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseConfigScalar unquotes a YAML scalar, strips trailing comments and joins
+// flow sequences with commas.
+func parseConfigScalar(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			return v[1 : end+1]
+		}
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		var items []string
+		for _, item := range strings.Split(v[1:len(v)-1], ",") {
+			if item = parseConfigScalar(strings.TrimSpace(item)); item != "" {
+				items = append(items, item)
+			}
+		}
+		return strings.Join(items, ",")
+	}
+	return v
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// controlPollInterval is how often a paused run checks whether the control
+// file is gone
+const controlPollInterval = 5 * time.Second
+
+// awaitControl blocks while the control file exists, so an operator can pause
+// deletions by creating it and resume them by removing it. Deletions already
+// in flight finish either way. A run asked to stop stops waiting.
+func (d destroyer) awaitControl(logger *zap.Logger) {
+	if d.controlFile == "" || !fileExists(d.controlFile) {
+		return
+	}
+	logger.Warn("Paused: control file exists", zap.String("control_file", d.controlFile))
+	start := time.Now()
+	for fileExists(d.controlFile) {
+		select {
+		case <-time.After(controlPollInterval):
+		case <-d.stop:
+			return
+		}
+	}
+	logger.Info("Resumed: control file removed", zap.Duration("paused_for", time.Since(start).Round(time.Second)))
+}
+
+// stopped reports whether the run has been asked to stop
+func (d destroyer) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// fileExists reports whether anything exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
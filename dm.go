@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+// maxDMPageSize is the most events the direct message events endpoint returns
+// per request
+const maxDMPageSize = 50
+
+// dmFetcher steps across the account's direct message events. Twitter only
+// returns events from the last 30 days.
+type dmFetcher struct {
+	client *twitter.Client
+	cursor string
+	done   bool
+
+	events []twitter.DirectMessageEvent
+	err    error
+}
+
+// newDMFetcher returns a new dmFetcher
+func newDMFetcher(client *twitter.Client) *dmFetcher {
+	return &dmFetcher{client: client}
+}
+
+// fetch gets the next page of direct message events. It returns false when
+// there are no more pages or an error occurred.
+func (f *dmFetcher) fetch() bool {
+	if f.done {
+		return false
+	}
+	var events *twitter.DirectMessageEvents
+	_, err := call(func() (resp *http.Response, err error) {
+		events, resp, err = f.client.DirectMessages.EventsList(&twitter.DirectMessageEventsListParams{
+			Cursor: f.cursor,
+			Count:  maxDMPageSize,
+		})
+		return resp, err
+	})
+	if err != nil {
+		f.err = err
+		return false
+	}
+	f.events = events.Events
+	f.cursor = events.NextCursor
+	f.done = f.cursor == ""
+	return len(f.events) > 0 || !f.done
+}
+
+// destroyDM deletes a direct message. Deleting only removes the message from
+// the account's own view of the conversation; the other party keeps their copy.
+func (d destroyer) destroyDM(logger *zap.Logger, id int64, dm twitter.DirectMessageEvent) error {
+	logger = logger.With(
+		zap.Int64("id", id))
+	if d.wasDestroyed(failureDM, id) {
+		logger.Debug("Already deleted this run")
+		return nil
+	}
+
+	var text string
+	if dm.Message != nil && dm.Message.Data != nil {
+		text = dm.Message.Data.Text
+	}
+	evict, err := d.retention.isDMTombstoned(id, dm.CreatedAt, text, d.now)
+	if err != nil {
+		return err
+	}
+	if !evict {
+		d.decide(logger, "Keeping Direct Message", id, text)
+		defer d.lock()()
+		d.stats.dmsKept++
+		return nil
+	}
+
+	if d.dryRun {
+		d.decide(logger, "Would delete Direct Message", id, text)
+		defer d.lock()()
+		d.stats.dmsDeleted++
+		return nil
+	}
+
+	logger.Info("Deleting Direct Message")
+	return d.async(logger, id, func() error {
+		deleted, err := d.deleteDM(logger, id)
+		if err != nil || !deleted {
+			return err
+		}
+		defer d.lock()()
+		d.stats.dmsDeleted++
+		return d.recordDeleted(failureDM, id)
+	})
+}
+
+// deleteDM deletes a direct message by ID. It reports whether the message was
+// deleted; messages that are already gone aren't.
+func (d destroyer) deleteDM(logger *zap.Logger, id int64) (bool, error) {
+	_, err := call(func() (*http.Response, error) {
+		return d.client.DirectMessages.EventsDestroy(strconv.FormatInt(id, 10))
+	})
+	return d.deleted(logger, failureDM, id, err)
+}
+
+// isDMTombstoned determines whether or not a direct message should be deleted.
+// Only the age, keep IDs and keywords apply; the other rules are about tweets.
+// createdAt is in milliseconds since the Unix epoch.
+func (r retention) isDMTombstoned(id int64, createdAt, text string, now time.Time) (bool, error) {
+	ms, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	if !olderThan(time.Unix(0, ms*int64(time.Millisecond)), now, r.maxAge) {
+		return false, nil
+	}
+	if len(r.onlyIDs) > 0 && !containsID(r.onlyIDs, id) {
+		return false, nil
+	}
+	if containsID(r.ids, id) {
+		return false, nil
+	}
+	for _, keyword := range r.keywords {
+		if strings.Contains(text, keyword) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+// dumpRecord is a line of the -dump-timeline file
+type dumpRecord struct {
+	// Kind is "tweet" or "favorite"
+	Kind  string        `json:"kind"`
+	Tweet twitter.Tweet `json:"tweet"`
+}
+
+// dumpTimeline writes the account's whole reachable timeline and favorites to
+// a JSON Lines file, replacing it if it exists. Nothing is evaluated against
+// the retention policy and nothing is deleted.
+func dumpTimeline(logger *zap.Logger, client *twitter.Client, account *twitter.User, cfg config) error {
+	f, err := os.Create(cfg.dumpTimeline)
+	if err != nil {
+		return fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	var tweets, favorites int
+	if cfg.targets.tweets {
+		tweetFetcher := newTweetFetcher(client, account.ScreenName, cfg.pageSize, false)
+		for tweetFetcher.fetch() {
+			for _, t := range tweetFetcher.tweets {
+				if err := enc.Encode(dumpRecord{Kind: failureTweet, Tweet: t}); err != nil {
+					return fmt.Errorf("failed to write dump file: %w", err)
+				}
+				tweets++
+			}
+		}
+		if tweetFetcher.err != nil {
+			return fmt.Errorf("failed to fetch: %w", tweetFetcher.err)
+		}
+	}
+	if cfg.targets.favorites {
+		favoriteFetcher := newFavoriteFetcher(client, account.ID, cfg.pageSize)
+		for favoriteFetcher.fetch() {
+			for _, t := range favoriteFetcher.tweets {
+				if err := enc.Encode(dumpRecord{Kind: failureFavorite, Tweet: t}); err != nil {
+					return fmt.Errorf("failed to write dump file: %w", err)
+				}
+				favorites++
+			}
+		}
+		if favoriteFetcher.err != nil {
+			return fmt.Errorf("failed to fetch: %w", favoriteFetcher.err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write dump file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write dump file: %w", err)
+	}
+	logger.Info("Dumped timeline",
+		zap.String("path", cfg.dumpTimeline),
+		zap.Int("tweets", tweets),
+		zap.Int("favorites", favorites))
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar units accepted by parseRetentionDuration. Months and years are
+// approximate; a few days either way doesn't matter for retention.
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+	year  = 365 * day
+)
+
+// parseRetentionDuration parses a Go duration or a whole number of days, months
+// or years, such as "90d", "18mo" or "2y".
+func parseRetentionDuration(v string) (time.Duration, error) {
+	for _, unit := range []struct {
+		suffix string
+		d      time.Duration
+	}{
+		{"mo", month},
+		{"d", day},
+		{"y", year},
+	} {
+		if !strings.HasSuffix(v, unit.suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(v, unit.suffix))
+		if err != nil {
+			break
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("duration %q is negative", v)
+		}
+		// A wrapped-around duration could be short enough to delete
+		// everything
+		if int64(n) > math.MaxInt64/int64(unit.d) {
+			return 0, fmt.Errorf("duration %q is too long", v)
+		}
+		return time.Duration(n) * unit.d, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// retentionDuration is a flag.Value for durations parsed with
+// parseRetentionDuration
+type retentionDuration time.Duration
+
+func (d *retentionDuration) Set(v string) error {
+	parsed, err := parseRetentionDuration(v)
+	if err != nil {
+		return err
+	}
+	*d = retentionDuration(parsed)
+	return nil
+}
+
+func (d *retentionDuration) String() string {
+	return time.Duration(*d).String()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetentionDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "720h", want: 720 * time.Hour},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "90d", want: 90 * day},
+		{in: "0d", want: 0},
+		{in: "18mo", want: 18 * month},
+		{in: "2y", want: 2 * year},
+		{in: "-1d", wantErr: true},
+		{in: "1.5d", wantErr: true},
+		{in: "d", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "ninety days", wantErr: true},
+		// 213503983 days is past the largest time.Duration and would wrap
+		// around to about a day
+		{in: "213503983d", wantErr: true},
+		{in: "106751d", want: 106751 * day},
+		{in: "300000y", wantErr: true},
+		{in: "9999999999999999999d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseRetentionDuration(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
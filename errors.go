@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Categories of errors from the Twitter API. Errors returned by classifyError
+// match exactly one of these with errors.Is. They're unexported like the rest
+// of package main, which nothing else can import.
+var (
+	errRateLimited = errors.New("rate limited")
+	errAuth        = errors.New("authentication failed")
+	errNotFound    = errors.New("not found")
+	errTransient   = errors.New("transient failure")
+	errPermanent   = errors.New("permanent failure")
+)
+
+// apiError is an error from the Twitter API along with its category
+type apiError struct {
+	category error
+	resp     *http.Response
+	err      error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+func (e apiError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is the error's category
+func (e apiError) Is(target error) bool {
+	return target == e.category
+}
+
+// classifyError sorts an error from the Twitter API into one of the error
+// categories. Authentication failures are returned as an authError.
+func classifyError(resp *http.Response, err error) error {
+	if err == nil {
+		return nil
+	}
+	var category error
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusTooManyRequests:
+		category = errRateLimited
+	case isNotFound(resp, err):
+		category = errNotFound
+	case isAuthFailure(resp, err):
+		return newAuthError(resp, err)
+	case resp == nil || resp.StatusCode >= http.StatusInternalServerError:
+		// No response at all means the request never made it, which is
+		// usually a network problem.
+		category = errTransient
+	default:
+		category = errPermanent
+	}
+	return apiError{
+		category: category,
+		resp:     resp,
+		err:      err,
+	}
+}
+
+// maxRateLimitRetries is how many 429s in a row call retries before giving up
+const maxRateLimitRetries = 5
+
+// minRateLimitWait is the least call waits after a 429, so a reset time that's
+// already past, or a skewed clock, doesn't have it retry straight away. It's a
+// variable for tests.
+var minRateLimitWait = 5 * time.Second
+
+// call makes a request to the Twitter API, backing off and retrying while rate
+// limited. Any error is classified with classifyError. After
+// maxRateLimitRetries 429s in a row, the error is returned.
+//
+// Backing off is per endpoint: rateLimitTransport refuses requests to an
+// endpoint whose window is used up, and call waits for that endpoint's window
+// to reset before trying again. Requests to other endpoints, say deletions
+// while the timeline is rate limited, carry on in the meantime.
+func call(do func() (*http.Response, error)) (*http.Response, error) {
+	retries := 0
+	for {
+		resp, err := do()
+		var limited rateLimitedError
+		if errors.As(err, &limited) {
+			time.Sleep(time.Until(limited.until))
+			continue
+		}
+		err = classifyError(resp, err)
+		if errors.Is(err, errRateLimited) {
+			if retries == maxRateLimitRetries {
+				return resp, fmt.Errorf("gave up after %d retries: %w", retries, err)
+			}
+			retries++
+			// The transport waits out the window on the next attempt, as
+			// long as the response said when it resets
+			reset, rerr := rateLimitReset(resp.Header)
+			if rerr != nil {
+				return resp, fmt.Errorf("failed to back off: %w", rerr)
+			}
+			if time.Until(reset) < minRateLimitWait {
+				time.Sleep(minRateLimitWait)
+			}
+			continue
+		}
+		return resp, err
+	}
+}
+
+// authError is returned when Twitter rejects a request because of bad
+// credentials or missing app permissions.
+type authError struct {
+	statusCode int
+	err        error
+}
+
+// newAuthError returns a new authError for a response rejected by
+// isAuthFailure
+func newAuthError(resp *http.Response, err error) authError {
+	return authError{
+		statusCode: resp.StatusCode,
+		err:        err,
+	}
+}
+
+func (e authError) Error() string {
+	msg := fmt.Sprintf("authentication failed (%d %s): check your OAuth credentials and app permissions (need Read+Write)",
+		e.statusCode, http.StatusText(e.statusCode))
+	if e.err == nil {
+		return msg
+	}
+	detail := e.err.Error()
+	if len(detail) > maxErrorDetail {
+		detail = detail[:maxErrorDetail] + "..."
+	}
+	if detail == "" {
+		return msg
+	}
+	return msg + ": " + detail
+}
+
+func (e authError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is errAuth
+func (e authError) Is(target error) bool {
+	return target == errAuth
+}
+
+// maxErrorDetail is the longest snippet of the API's error response included
+// in error messages.
+const maxErrorDetail = 200
+
+// Twitter's error codes for credentials or app permissions that are no good
+// for any request
+var authErrorCodes = map[int]bool{
+	32:  true, // could not authenticate you
+	89:  true, // invalid or expired token
+	93:  true, // app not allowed to access direct messages
+	135: true, // timestamp out of bounds
+	215: true, // bad authentication data
+	220: true, // credentials don't allow access to this resource
+	261: true, // app cannot perform write actions
+}
+
+// isAuthFailure reports whether the response was rejected because of the
+// credentials or the app's permissions. Every 401 is, but Twitter also answers
+// 403 for failures particular to one tweet, such as 179 (not authorized to see
+// the status) or 187 (duplicate status), so a 403 only counts when its error
+// codes say so.
+func isAuthFailure(resp *http.Response, err error) bool {
+	if resp == nil {
+		return false
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, detail := range apiErr.Errors {
+		if authErrorCodes[detail.Code] {
+			return true
+		}
+	}
+	return false
+}
+
+// Twitter's error codes for accounts that can't be used
+const (
+	errCodeAccountSuspended = 64
+	errCodeAccountLocked    = 326
+)
+
+// accountStatusError returns an actionable error when err shows the account
+// is suspended or locked, and nil otherwise. Every deletion would fail, so
+// there's no point continuing.
+func accountStatusError(err error) error {
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+	for _, detail := range apiErr.Errors {
+		switch detail.Code {
+		case errCodeAccountSuspended:
+			return fmt.Errorf("account is suspended: nothing can be deleted until Twitter lifts the suspension")
+		case errCodeAccountLocked:
+			return fmt.Errorf("account is temporarily locked: log in to twitter.com to unlock it, then run again")
+		}
+	}
+	return nil
+}
+
+// errCodeEndpointRetired is Twitter's error code for a retired endpoint
+const errCodeEndpointRetired = 251
+
+// errFavoritesDeprecated is returned when Twitter refuses to list favorites
+// because the endpoint is deprecated for the app or account.
+var errFavoritesDeprecated = errors.New("twitter no longer serves favorites/list for this app or account, so favorites can't be pruned; check the app's API access level in the developer portal")
+
+// isDeprecated reports whether a request failed because Twitter has
+// deprecated or retired the endpoint.
+func isDeprecated(err error) bool {
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, detail := range apiErr.Errors {
+		if detail.Code == errCodeEndpointRetired || strings.Contains(strings.ToLower(detail.Message), "deprecated") {
+			return true
+		}
+	}
+	return false
+}
+
+// errCodeDuplicateStatus is Twitter's error code for a status identical to a
+// recent one
+const errCodeDuplicateStatus = 187
+
+// isDuplicateStatus reports whether posting a status failed because the same
+// text was posted recently.
+func isDuplicateStatus(err error) bool {
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, detail := range apiErr.Errors {
+		if detail.Code == errCodeDuplicateStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// errCodeNoStatusFound is Twitter's error code for "No status found with that ID"
+const errCodeNoStatusFound = 144
+
+// isNotFound reports whether a request failed because the tweet no longer
+// exists, usually because it was deleted out-of-band.
+func isNotFound(resp *http.Response, err error) bool {
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return true
+	}
+	var apiErr twitter.APIError
+	if errors.As(err, &apiErr) {
+		for _, detail := range apiErr.Errors {
+			if detail.Code == errCodeNoStatusFound {
+				return true
+			}
+		}
+	}
+	return false
+}
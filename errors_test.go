@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func apiErrorWithCode(code int) error {
+	return twitter.APIError{Errors: []twitter.ErrorDetail{{Code: code}}}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		category error
+	}{
+		{"rate limited", http.StatusTooManyRequests, apiErrorWithCode(88), errRateLimited},
+		{"not found", http.StatusNotFound, apiErrorWithCode(144), errNotFound},
+		{"no status found", http.StatusForbidden, apiErrorWithCode(errCodeNoStatusFound), errNotFound},
+		{"unauthorized", http.StatusUnauthorized, apiErrorWithCode(32), errAuth},
+		{"unauthorized without code", http.StatusUnauthorized, errors.New("unauthorized"), errAuth},
+		{"expired token", http.StatusForbidden, apiErrorWithCode(89), errAuth},
+		{"read-only app", http.StatusForbidden, apiErrorWithCode(261), errAuth},
+		{"not authorized to see status", http.StatusForbidden, apiErrorWithCode(179), errPermanent},
+		{"can't delete", http.StatusForbidden, apiErrorWithCode(183), errPermanent},
+		{"duplicate status", http.StatusForbidden, apiErrorWithCode(187), errPermanent},
+		{"forbidden without code", http.StatusForbidden, errors.New("forbidden"), errPermanent},
+		{"server error", http.StatusServiceUnavailable, errors.New("unavailable"), errTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(&http.Response{StatusCode: tt.status}, tt.err)
+			for _, category := range []error{errRateLimited, errAuth, errNotFound, errTransient, errPermanent} {
+				if got, want := errors.Is(err, category), category == tt.category; got != want {
+					t.Errorf("errors.Is(%v) = %v, want %v", category, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyErrorNoResponse(t *testing.T) {
+	if err := classifyError(nil, errors.New("connection refused")); !errors.Is(err, errTransient) {
+		t.Errorf("got %v, want a transient error", err)
+	}
+	if err := classifyError(nil, nil); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestIsDuplicateStatus(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusForbidden}
+	if !isDuplicateStatus(classifyError(resp, apiErrorWithCode(errCodeDuplicateStatus))) {
+		t.Error("code 187 isn't a duplicate status")
+	}
+	if isDuplicateStatus(classifyError(resp, apiErrorWithCode(179))) {
+		t.Error("code 179 is a duplicate status")
+	}
+	if isDuplicateStatus(nil) {
+		t.Error("nil is a duplicate status")
+	}
+}
+
+func TestCallRateLimited(t *testing.T) {
+	defer func(wait time.Duration) { minRateLimitWait = wait }(minRateLimitWait)
+	minRateLimitWait = 20 * time.Millisecond
+	tests := []struct {
+		name        string
+		limited     int
+		reset       time.Time
+		wantErr     error
+		wantSent    int
+		wantWaitMin time.Duration
+	}{
+		{"not limited", 0, time.Now(), nil, 1, 0},
+		{"reset in the past", 2, time.Now().Add(-time.Hour), nil, 3, 40 * time.Millisecond},
+		{"never resets", 100, time.Now().Add(-time.Hour), errRateLimited, maxRateLimitRetries + 1, maxRateLimitRetries * 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := 0
+			start := time.Now()
+			_, err := call(func() (*http.Response, error) {
+				sent++
+				if sent > tt.limited {
+					return &http.Response{StatusCode: http.StatusOK}, nil
+				}
+				header := http.Header{}
+				header.Set("X-Rate-Limit-Reset", strconv.FormatInt(tt.reset.Unix(), 10))
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, apiErrorWithCode(88)
+			})
+			if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if sent != tt.wantSent {
+				t.Errorf("sent %d requests, want %d", sent, tt.wantSent)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantWaitMin {
+				t.Errorf("waited %s, want at least %s", elapsed, tt.wantWaitMin)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Kinds of failed deletions
+const (
+	failureTweet    = "tweet"
+	failureFavorite = "favorite"
+	failureDM       = "dm"
+)
+
+// failure is a deletion that failed
+type failure struct {
+	kind string
+	id   int64
+	err  string
+}
+
+// failedFile appends failed deletions to a file, one per line, as the kind,
+// the ID and the error separated by tabs.
+type failedFile struct {
+	file *os.File
+}
+
+// openFailedFile opens a failed file for appending, creating it if necessary
+func openFailedFile(path string) (*failedFile, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &failedFile{file: f}, nil
+}
+
+// add writes a failure to the file. Failures are rare, so each is synced to
+// disk immediately.
+func (f *failedFile) add(fail failure) error {
+	msg := strings.NewReplacer("\t", " ", "\n", " ").Replace(fail.err)
+	if _, err := fmt.Fprintf(f.file, "%s\t%d\t%s\n", fail.kind, fail.id, msg); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Close closes the file
+func (f *failedFile) Close() error {
+	return f.file.Close()
+}
+
+// readFailedFile reads the failures recorded in a failed file
+func readFailedFile(path string) ([]failure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		failures []failure
+		scanner  = bufio.NewScanner(f)
+		line     int
+	)
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		parts := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected kind and ID", path, line)
+		}
+		if parts[0] != failureTweet && parts[0] != failureFavorite && parts[0] != failureDM {
+			return nil, fmt.Errorf("%s:%d: unknown kind %q", path, line, parts[0])
+		}
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid ID %q", path, line, parts[1])
+		}
+		fail := failure{kind: parts[0], id: id}
+		if len(parts) == 3 {
+			fail.err = parts[2]
+		}
+		failures = append(failures, fail)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
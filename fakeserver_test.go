@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// fakeTwitter is a test server for the parts of the Twitter API a prune run
+// uses: verifying credentials, paging through the timeline and favorites, and
+// deleting. Point -api-base-url at its URL.
+type fakeTwitter struct {
+	*httptest.Server
+	account twitter.User
+
+	mu     sync.Mutex
+	tweets []twitter.Tweet // newest first, as the timeline lists them
+	// timelineLimit, when set, is how many of the most recent tweets the
+	// timeline reaches, like the API's 3,200
+	timelineLimit int
+	favorites     []twitter.Tweet
+	// lists maps a list ID to its timeline, newest first
+	lists       map[int64][]twitter.Tweet
+	pinnedID    int64
+	destroyed   []int64
+	unfavorited []int64
+	limited     map[string]int // endpoint path to the 429s left to send
+	requests    map[string]int // endpoint path to the requests it got
+	// timelineMaxIDs, timelineCounts and timelineTrimUser hold the max_id,
+	// count and trim_user of each timeline request, in order
+	timelineMaxIDs   []int64
+	timelineCounts   []int
+	timelineTrimUser []string
+	// broken holds tweet IDs whose deletion fails with a server error
+	broken map[int64]bool
+	// onDestroy, when set, is called with each tweet deleted
+	onDestroy func(id int64)
+	// users are the accounts users/lookup finds, and userLookups the user_id
+	// of each users/lookup request, in order
+	users       map[int64]twitter.User
+	userLookups []string
+}
+
+// newFakeTwitter starts a fakeTwitter for the account. It's closed when the
+// test finishes.
+func newFakeTwitter(t *testing.T, account twitter.User) *fakeTwitter {
+	f := &fakeTwitter{
+		account:  account,
+		limited:  map[string]int{},
+		requests: map[string]int{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	// The fake's windows reset within a second, which is all a rate limited
+	// request should wait
+	wait := minRateLimitWait
+	minRateLimitWait = 0
+	t.Cleanup(func() { minRateLimitWait = wait })
+	return f
+}
+
+// rateLimit makes the next n requests to the endpoint at path fail with a 429,
+// with a window resetting in a second
+func (f *fakeTwitter) rateLimit(path string, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.limited[path] = n
+}
+
+func (f *fakeTwitter) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	if strings.HasPrefix(path, "/1.1/statuses/destroy/") {
+		path = "/1.1/statuses/destroy/:id.json"
+	}
+	f.requests[path]++
+	if f.limited[path] > 0 {
+		f.limited[path]--
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		w.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+		writeAPIError(w, http.StatusTooManyRequests, 88, "Rate limit exceeded")
+		return
+	}
+
+	switch path {
+	case "/1.1/account/verify_credentials.json":
+		writeJSON(w, f.account)
+	case "/1.1/statuses/user_timeline.json":
+		maxID, _ := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+		count, _ := strconv.Atoi(r.FormValue("count"))
+		f.timelineMaxIDs = append(f.timelineMaxIDs, maxID)
+		f.timelineCounts = append(f.timelineCounts, count)
+		f.timelineTrimUser = append(f.timelineTrimUser, r.FormValue("trim_user"))
+		reachable := f.tweets
+		if f.timelineLimit > 0 && len(reachable) > f.timelineLimit {
+			reachable = reachable[:f.timelineLimit]
+		}
+		writeJSON(w, page(reachable, r))
+	case "/1.1/lists/statuses.json":
+		listID, _ := strconv.ParseInt(r.FormValue("list_id"), 10, 64)
+		tweets, ok := f.lists[listID]
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, 34, "Sorry, that page does not exist.")
+			return
+		}
+		writeJSON(w, page(tweets, r))
+	case "/1.1/search/tweets.json":
+		writeJSON(w, twitter.Search{Statuses: []twitter.Tweet{}})
+	case "/1.1/statuses/lookup.json":
+		found := []twitter.Tweet{}
+		for _, s := range strings.Split(r.FormValue("id"), ",") {
+			id, _ := strconv.ParseInt(s, 10, 64)
+			for _, t := range f.tweets {
+				if t.ID == id {
+					found = append(found, t)
+				}
+			}
+		}
+		writeJSON(w, found)
+	case "/1.1/users/lookup.json":
+		f.userLookups = append(f.userLookups, r.FormValue("user_id"))
+		found := []twitter.User{}
+		for _, s := range strings.Split(r.FormValue("user_id"), ",") {
+			id, _ := strconv.ParseInt(s, 10, 64)
+			if u, ok := f.users[id]; ok {
+				found = append(found, u)
+			}
+		}
+		if len(found) == 0 {
+			writeAPIError(w, http.StatusNotFound, 17, "No user matches for specified terms.")
+			return
+		}
+		writeJSON(w, found)
+	case "/1.1/favorites/list.json":
+		writeJSON(w, page(f.favorites, r))
+	case "/1.1/statuses/destroy/:id.json":
+		id, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/1.1/statuses/destroy/"), ".json"), 10, 64)
+		if f.broken[id] {
+			writeAPIError(w, http.StatusInternalServerError, 131, "Internal error")
+			return
+		}
+		var ok bool
+		if f.tweets, ok = remove(f.tweets, id); !ok {
+			writeAPIError(w, http.StatusNotFound, 144, "No status found with that ID.")
+			return
+		}
+		f.destroyed = append(f.destroyed, id)
+		if f.onDestroy != nil {
+			f.onDestroy(id)
+		}
+		writeJSON(w, twitter.Tweet{ID: id})
+	case "/1.1/favorites/destroy.json":
+		id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		var ok bool
+		if f.favorites, ok = remove(f.favorites, id); !ok {
+			writeAPIError(w, http.StatusNotFound, 144, "No status found with that ID.")
+			return
+		}
+		f.unfavorited = append(f.unfavorited, id)
+		writeJSON(w, twitter.Tweet{ID: id})
+	case "/2/users/" + f.account.IDStr:
+		var data struct {
+			PinnedTweetID string `json:"pinned_tweet_id,omitempty"`
+		}
+		if f.pinnedID != 0 {
+			data.PinnedTweetID = strconv.FormatInt(f.pinnedID, 10)
+		}
+		writeJSON(w, map[string]interface{}{"data": data})
+	default:
+		writeAPIError(w, http.StatusNotFound, 34, "Sorry, that page does not exist.")
+	}
+}
+
+// page returns the tweets the request's count and max_id select
+func page(tweets []twitter.Tweet, r *http.Request) []twitter.Tweet {
+	count, _ := strconv.Atoi(r.FormValue("count"))
+	maxID, _ := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+	res := []twitter.Tweet{}
+	for _, t := range tweets {
+		if maxID != 0 && t.ID > maxID {
+			continue
+		}
+		if count > 0 && len(res) == count {
+			break
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// remove returns tweets without the one with the ID, and whether it was there
+func remove(tweets []twitter.Tweet, id int64) ([]twitter.Tweet, bool) {
+	for i, t := range tweets {
+		if t.ID == id {
+			return append(tweets[:i:i], tweets[i+1:]...), true
+		}
+	}
+	return tweets, false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(twitter.APIError{
+		Errors: []twitter.ErrorDetail{{Code: code, Message: message}},
+	})
+}
+
+// runPrune runs the prune command against f with the given flags, in addition
+// to credentials and an empty config file
+func runPrune(t *testing.T, f *fakeTwitter, args ...string) error {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(configPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pruneCommand("prune", append([]string{
+		"-config=" + configPath,
+		"-api-base-url=" + f.URL,
+		"-consumer-key=key",
+		"-consumer-secret=secret",
+		"-oauth-token=token",
+		"-oauth-token-secret=token-secret",
+	}, args...), false)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// firstArchivedTweet returns the ID of the earliest tweet in an archive that
+// isn't a retweet, or 0 if there isn't one. Archives don't mark retweets other
+// than by their "RT @" text.
+func firstArchivedTweet(tweets []archiveTweet) int64 {
+	var first int64
+	for _, at := range tweets {
+		if strings.HasPrefix(at.text(), "RT @") {
+			continue
+		}
+		if id := at.tweetID(); first == 0 || id < first {
+			first = id
+		}
+	}
+	return first
+}
+
+// oldestReachableTweet pages through the whole timeline and returns the ID of
+// the oldest tweet it reaches that isn't a retweet, or 0 if there isn't one.
+// The timeline stops at the most recent 3,200 tweets, so for older accounts
+// this isn't the first tweet.
+func oldestReachableTweet(client *twitter.Client, username string, pageSize int) (int64, error) {
+	var (
+		oldest  int64
+		fetcher = newTweetFetcher(client, username, pageSize, true)
+	)
+	for fetcher.fetch() {
+		for _, t := range fetcher.tweets {
+			if t.RetweetedStatus == nil {
+				oldest = t.ID
+			}
+		}
+	}
+	if fetcher.err != nil {
+		return 0, fetcher.err
+	}
+	return oldest, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestFirstArchivedTweet(t *testing.T) {
+	tests := []struct {
+		name   string
+		tweets []archiveTweet
+		want   int64
+	}{
+		{"empty", nil, 0},
+		{
+			name: "earliest id",
+			tweets: []archiveTweet{
+				{ID: 30, FullText: "third"},
+				{ID: 10, FullText: "first"},
+				{ID: 20, FullText: "second"},
+			},
+			want: 10,
+		},
+		{
+			name: "skips retweets",
+			tweets: []archiveTweet{
+				{ID: 20, FullText: "second"},
+				{ID: 10, FullText: "RT @someone: first"},
+			},
+			want: 20,
+		},
+		{
+			name:   "only retweets",
+			tweets: []archiveTweet{{ID: 10, FullText: "RT @someone: first"}},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstArchivedTweet(tt.tweets); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneKeepFirstTweet(t *testing.T) {
+	tests := []struct {
+		name          string
+		retweet       bool
+		limited       int
+		wantDestroyed []int64
+	}{
+		{"first tweet", false, 0, []int64{104, 103, 102}},
+		{"first tweet retweeted", true, 0, []int64{104, 103, 101}},
+		{"rate limited", false, 1, []int64{104, 103, 102}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			if tt.retweet {
+				f.tweets[5].RetweetedStatus = &twitter.Tweet{ID: 1}
+			}
+			// The first request is the pass looking for the first tweet
+			f.rateLimit("/1.1/statuses/user_timeline.json", tt.limited)
+			err := runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-keep-first-tweet", "-page-size=2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+			if f.limited["/1.1/statuses/user_timeline.json"] > 0 {
+				t.Error("the timeline was never rate limited")
+			}
+			// Four pages each for finding the first tweet and for the run
+			if n := len(f.timelineCounts); n != 8 {
+				t.Errorf("fetched %d timeline pages, want 8", n)
+			}
+			for _, count := range f.timelineCounts {
+				if count != 2 {
+					t.Errorf("fetched pages of %v, want all of -page-size", f.timelineCounts)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+// Tweet IDs since November 2010 are snowflakes, which start with a timestamp
+const (
+	minSnowflakeID = 29700859247
+	snowflakeEpoch = 1288834974657 // milliseconds since the Unix epoch
+)
+
+// snowflakeTime returns when a snowflake ID was generated
+func snowflakeTime(id int64) time.Time {
+	ms := id>>22 + snowflakeEpoch
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// gapDetector watches pages of the timeline for signs the API skipped part of
+// it: a long stretch of time between one page and the next, or a page far
+// shorter than asked for with more pages after it. Neither is necessarily
+// wrong, so they're only logged.
+type gapDetector struct {
+	threshold time.Duration
+	pageSize  int
+
+	lastID    int64
+	shortPage int
+}
+
+// newGapDetector returns a gapDetector that warns about gaps longer than
+// threshold. A zero threshold only checks page sizes.
+func newGapDetector(threshold time.Duration, pageSize int) *gapDetector {
+	return &gapDetector{
+		threshold: threshold,
+		pageSize:  pageSize,
+		shortPage: -1,
+	}
+}
+
+// check looks at the next page of tweets
+func (g *gapDetector) check(logger *zap.Logger, tweets []twitter.Tweet) {
+	if len(tweets) == 0 {
+		return
+	}
+	first, last := tweets[0].ID, tweets[len(tweets)-1].ID
+
+	if g.shortPage >= 0 {
+		logger.Warn("Timeline returned a short page before the end; some tweets may have been skipped",
+			zap.Int("page_size", g.shortPage),
+			zap.Int64("after_id", g.lastID))
+	}
+	if g.threshold > 0 && g.lastID >= minSnowflakeID && first >= minSnowflakeID {
+		if gap := snowflakeTime(g.lastID).Sub(snowflakeTime(first)); gap > g.threshold {
+			logger.Warn("Large gap in the timeline; check the account for tweets between these",
+				zap.Int64("newer_id", g.lastID),
+				zap.Int64("older_id", first),
+				zap.Duration("gap", gap))
+		}
+	}
+
+	// Twitter's pages often come up a little short because of deleted or
+	// withheld tweets; only a near-empty one is suspicious.
+	g.shortPage = -1
+	if len(tweets) < g.pageSize/10 {
+		g.shortPage = len(tweets)
+	}
+	g.lastID = last
+}
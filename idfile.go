@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// idFileSyncEvery is how many IDs are written between syncs to disk
+const idFileSyncEvery = 10
+
+// idFile appends tweet IDs to a newline-delimited file
+type idFile struct {
+	file    *os.File
+	w       *bufio.Writer
+	pending int
+}
+
+// openIDFile opens a file for appending IDs, creating it if necessary
+func openIDFile(path string) (*idFile, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &idFile{
+		file: f,
+		w:    bufio.NewWriter(f),
+	}, nil
+}
+
+// add writes an ID to the file. IDs are flushed and synced to disk every few
+// writes so that a crash loses at most a handful.
+func (f *idFile) add(id int64) error {
+	if _, err := fmt.Fprintln(f.w, id); err != nil {
+		return err
+	}
+	f.pending++
+	if f.pending < idFileSyncEvery {
+		return nil
+	}
+	return f.sync()
+}
+
+// sync flushes buffered IDs and syncs the file to disk
+func (f *idFile) sync() error {
+	f.pending = 0
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Close syncs any remaining IDs and closes the file
+func (f *idFile) Close() error {
+	if err := f.sync(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// keptRecord is a line of the -report-kept file
+type keptRecord struct {
+	// Kind is "tweet" or "favorite"
+	Kind   string `json:"kind"`
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+// keptReport writes the tweets a dry run keeps, and the rule keeping each, to
+// a JSON Lines file
+type keptReport struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+// createKeptReport creates a kept report, replacing the file if it exists
+func createKeptReport(path string) (*keptReport, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &keptReport{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// add records a kept tweet of the given kind
+func (k *keptReport) add(kind string, t twitter.Tweet, reason string) error {
+	return k.enc.Encode(keptRecord{
+		Kind:   kind,
+		ID:     t.ID,
+		Reason: reason,
+		Text:   t.Text,
+	})
+}
+
+func (k *keptReport) Close() error {
+	return k.file.Close()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// limitedEndpoints are the endpoints tprune uses, keyed by their rate-limit
+// resource family.
+var limitedEndpoints = []struct {
+	family, endpoint string
+}{
+	{"statuses", "/statuses/user_timeline"},
+	{"statuses", "/statuses/destroy/:id"},
+	{"favorites", "/favorites/list"},
+	{"favorites", "/favorites/destroy"},
+	{"search", "/search/tweets"},
+}
+
+// limitsCommand prints the current rate-limit status of the endpoints tprune
+// uses. Twitter doesn't report limits for the destroy endpoints, so they're
+// listed as unreported.
+func limitsCommand(name string, args []string) error {
+	var (
+		cfg        config
+		configPath string
+	)
+	flagset := flag.NewFlagSet("tprune "+name, flag.ExitOnError)
+	cfg.registerCommonFlags(flagset, &configPath)
+	if err := flagset.Parse(args); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := applyConfigFile(flagset, configPath, false); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.validateCommon(); err != nil {
+		printErrors(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to setup http client: %w", err)
+	}
+	client := twitter.NewClient(httpClient)
+
+	var limits *twitter.RateLimit
+	_, err = call(func() (resp *http.Response, err error) {
+		limits, resp, err = client.RateLimits.Status(&twitter.RateLimitParams{
+			Resources: []string{"statuses", "favorites", "search"},
+		})
+		return resp, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch rate limits: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tREMAINING\tLIMIT\tRESETS")
+	for _, e := range limitedEndpoints {
+		resource := lookupRateLimit(limits, e.family, e.endpoint)
+		if resource == nil {
+			fmt.Fprintf(w, "%s\tnot reported\t\t\n", e.endpoint)
+			continue
+		}
+		reset := time.Unix(int64(resource.Reset), 0)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s (in %s)\n", e.endpoint, resource.Remaining, resource.Limit,
+			reset.Format(time.RFC3339), time.Until(reset).Round(time.Second))
+	}
+	return w.Flush()
+}
+
+// lookupRateLimit returns the rate limit for an endpoint, or nil if Twitter
+// didn't report one.
+func lookupRateLimit(limits *twitter.RateLimit, family, endpoint string) *twitter.RateLimitResource {
+	if limits == nil || limits.Resources == nil {
+		return nil
+	}
+	var resources map[string]*twitter.RateLimitResource
+	switch family {
+	case "statuses":
+		resources = limits.Resources.Statuses
+	case "favorites":
+		resources = limits.Resources.Favorites
+	case "search":
+		resources = limits.Resources.Search
+	}
+	return resources[endpoint]
+}
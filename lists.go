@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// listTweetIDs returns the IDs of the account's own tweets in a list's
+// timeline. Twitter only serves the most recent several hundred tweets of a
+// list's timeline.
+func listTweetIDs(client *twitter.Client, listID, accountID int64, pageSize int) ([]int64, error) {
+	var (
+		ids     []int64
+		fetcher = newListFetcher(client, listID, pageSize)
+	)
+	for fetcher.fetch() {
+		for _, t := range fetcher.tweets {
+			if t.User != nil && t.User.ID == accountID {
+				ids = append(ids, t.ID)
+			}
+		}
+	}
+	if fetcher.err != nil {
+		return nil, fetcher.err
+	}
+	return ids, nil
+}
+
+// listFetcher steps across all tweets in a list's timeline
+type listFetcher struct {
+	client   *twitter.Client
+	listID   int64
+	pageSize int
+	maxID    int64
+
+	tweets []twitter.Tweet
+	err    error
+}
+
+// newListFetcher returns a new list fetcher
+func newListFetcher(client *twitter.Client, listID int64, pageSize int) *listFetcher {
+	return &listFetcher{
+		client:   client,
+		listID:   listID,
+		pageSize: pageSize,
+	}
+}
+
+// fetch gets a list of the list's tweets. It should be called continuously as
+// an iterator. A return value of "true" means there are potentially more
+// tweets to be fetched. A value of "false" means there are no more tweets to
+// be fetched.
+//
+// The resulting tweets are stored in the "tweets" struct field. Any errors that
+// occur will be reflected in the "err" field.
+func (f *listFetcher) fetch() bool {
+	var (
+		on     = true
+		params = &twitter.ListsStatusesParams{
+			ListID:          f.listID,
+			Count:           f.pageSize,
+			MaxID:           f.maxID,
+			IncludeRetweets: &on,
+		}
+	)
+	_, err := call(func() (resp *http.Response, err error) {
+		f.tweets, resp, err = f.client.Lists.Statuses(params)
+		return resp, err
+	})
+	if err != nil {
+		f.err = fmt.Errorf("failed to fetch list %d: %w", f.listID, err)
+		return false
+	}
+	if len(f.tweets) > 0 {
+		f.maxID = f.tweets[len(f.tweets)-1].ID - 1
+		return true
+	}
+	return false
+}
+
+// result returns the most recently fetched tweets
+func (f *listFetcher) result() ([]twitter.Tweet, error) {
+	return f.tweets, f.err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestParseListIDs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int64
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "7", want: []int64{7}},
+		{in: "7, 8", want: []int64{7, 8}},
+		{in: "7,", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseListIDs(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// listedAt returns a tweet by the user with the ID and creation time
+func listedAt(id int64, t time.Time, user twitter.User) twitter.Tweet {
+	tweet := tweetAt(id, t)
+	tweet.User = &user
+	return tweet
+}
+
+func TestPruneKeepFromList(t *testing.T) {
+	tests := []struct {
+		name          string
+		limited       int
+		wantDestroyed []int64
+	}{
+		{"kept", 0, []int64{104, 102}},
+		{"rate limited", 1, []int64{104, 102}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			now := time.Now()
+			other := twitter.User{ID: 7, IDStr: "7", ScreenName: "other"}
+			f.lists = map[int64][]twitter.Tweet{
+				9: {
+					listedAt(200, now.Add(-time.Hour), other),
+					listedAt(103, now.Add(-50*day), testAccount),
+					listedAt(150, now.Add(-45*day), other),
+					listedAt(101, now.Add(-70*day), testAccount),
+				},
+			}
+			f.rateLimit("/1.1/lists/statuses.json", tt.limited)
+			err := runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-keep-from-list=9", "-page-size=2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+			if f.limited["/1.1/lists/statuses.json"] > 0 {
+				t.Error("the list was never rate limited")
+			}
+			// Two full pages of -page-size and an empty one, and the rate
+			// limited one
+			if n, want := f.requests["/1.1/lists/statuses.json"], 3+tt.limited; n != want {
+				t.Errorf("fetched %d list pages, want %d", n, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// logSampleTick is the interval log sampling counts are reset over
+const logSampleTick = time.Second
+
+// parseLogSample parses a -log-sample value of the form "initial,thereafter".
+// An empty value disables sampling and returns zeros.
+func parseLogSample(v string) (initial, thereafter int, err error) {
+	if v == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("-log-sample must be of the form initial,thereafter")
+	}
+	if initial, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil || initial < 1 {
+		return 0, 0, fmt.Errorf("-log-sample initial count must be a positive number")
+	}
+	if thereafter, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || thereafter < 0 {
+		return 0, 0, fmt.Errorf("-log-sample thereafter count must not be negative")
+	}
+	return initial, thereafter, nil
+}
+
+// sampledInfoCore samples entries below warn level, such as the per-tweet
+// decisions, and lets warnings and errors through untouched. Zap samples by
+// message, so one-off entries like the end-of-run summary are always logged.
+type sampledInfoCore struct {
+	zapcore.Core
+	sampled zapcore.Core
+}
+
+// newSampledInfoCore wraps core, logging the first initial entries with each
+// message every tick and every thereafter-th entry after that.
+func newSampledInfoCore(core zapcore.Core, initial, thereafter int) zapcore.Core {
+	return sampledInfoCore{
+		Core:    core,
+		sampled: zapcore.NewSamplerWithOptions(core, logSampleTick, initial, thereafter),
+	}
+}
+
+func (c sampledInfoCore) With(fields []zapcore.Field) zapcore.Core {
+	return sampledInfoCore{
+		Core:    c.Core.With(fields),
+		sampled: c.sampled.With(fields),
+	}
+}
+
+func (c sampledInfoCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level >= zapcore.WarnLevel {
+		return c.Core.Check(ent, ce)
+	}
+	return c.sampled.Check(ent, ce)
+}
@@ -2,42 +2,177 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 func main() {
+	name, args := "prune", os.Args[1:]
+	if len(args) > 0 && (args[0] == "-version" || args[0] == "--version") {
+		fmt.Println(versionString())
+		return
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	var err error
+	switch name {
+	case "prune":
+		err = pruneCommand(name, args, false)
+	case "plan":
+		err = pruneCommand(name, args, true)
+	case "verify":
+		err = verifyCommand(name, args)
+	case "retry":
+		err = retryCommand(name, args)
+	case "limits":
+		err = limitsCommand(name, args)
+	default:
+		fmt.Printf("unknown command %q: expected prune, plan, verify, retry or limits\n", name)
+		os.Exit(2)
+	}
+
+	// Do it to it, Lars!
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// pruneCommand deletes tweets and favorites according to the retention policy.
+// The plan command is the same but never deletes anything.
+func pruneCommand(name string, args []string, plan bool) error {
 	var (
-		cfg          config
-		keepIDs      string
-		keepKeywords string
+		cfg             config
+		keepIDs         string
+		keepKeywords    string
+		keepLangs       string
+		onlyIDs         string
+		configPath      string
+		keepMentions    string
+		keepHashtags    string
+		deleteIfNone    string
+		keepFromLists   string
+		keepRepliesTo   string
+		keepIDRanges    string
+		matchQuery      string
+		targetList      string
+		strategy        string
+		tweetsOnly      bool
+		favesOnly       bool
+		continueOnError bool
+		logSample       string
 	)
-	flagset := flag.NewFlagSet("tprune", flag.ExitOnError)
-	flagset.StringVar(&cfg.username, "username", "", "Username to target")
-	flagset.StringVar(&cfg.consumerKey, "consumer-key", "", "Twitter Consumer Key")
-	flagset.StringVar(&cfg.consumerSecret, "consumer-secret", "", "Twitter Consumer Secret")
-	flagset.StringVar(&cfg.oauthToken, "oauth-token", "", "Twitter OAuth Token")
-	flagset.StringVar(&cfg.oauthTokenSecret, "oauth-token-secret", "", "Twitter OAuth Token Secret")
-	flagset.DurationVar(&cfg.retention.maxAge, "max-age", 0, "Maximum age to keep. Tweets older than this will be deleted.")
-	flagset.StringVar(&cfg.logLevel, "log-level", "info", "Log level")
-	flagset.StringVar(&keepIDs, "keep-ids", "", "Tweet IDs to keep forever.")
+	flagset := flag.NewFlagSet("tprune "+name, flag.ExitOnError)
+	cfg.registerCommonFlags(flagset, &configPath)
+	flagset.StringVar(&cfg.username, "username", "", "Username to target. Defaults to, and must be, the account the credentials belong to.")
+	flagset.BoolVar(&cfg.skipVerify, "skip-verify", false, "Don't check the credentials with account/verify_credentials before starting, for servers that don't implement it. Requires -username and -account-id.")
+	flagset.Int64Var(&cfg.accountID, "account-id", 0, "Numeric ID of the account -username names. With -username, the account isn't looked up with account/verify_credentials, saving a request.")
+	flagset.Var((*retentionDuration)(&cfg.retention.maxAge), "max-age", "Maximum age to keep, as a duration (\"720h\") or days, months or years (\"90d\", \"18mo\", \"2y\"). Tweets strictly older than this will be deleted.")
+	flagset.Var((*retentionDuration)(&cfg.retention.mediaMaxAge), "media-max-age", "Maximum age to keep tweets with photos, videos or GIFs attached, in place of -max-age. Takes the same values as -max-age.")
+	flagset.BoolVar(&cfg.retention.keepGeotagged, "keep-geotagged", false, "Keep tweets with a location attached.")
+	flagset.BoolVar(&cfg.keepFirstTweet, "keep-first-tweet", false, "Keep the account's first tweet: the earliest in -archive, or else the oldest the timeline reaches.")
+	flagset.IntVar(&cfg.keepTopN, "keep-top-n", 0, "Keep the N most favorited tweets that are old enough to be deleted, not counting retweets. Takes an extra pass through the timeline, or -archive, before deleting.")
+	flagset.BoolVar(&cfg.keepPinned, "keep-pinned", true, "Keep the account's pinned tweet.")
+	flagset.BoolVar(&cfg.retention.keepSelfQuotes, "keep-self-quotes", false, "Keep tweets quoting your own tweets. Tweets are fetched with full user objects rather than trimmed ones, so pages are larger.")
+	flagset.BoolVar(&cfg.keepVerifiedReplies, "keep-verified-replies", false, "Keep replies to verified accounts. Costs a users/lookup request per page of tweets.")
+	flagset.BoolVar(&cfg.retention.onlyText, "only-text", false, "Only delete text-only tweets, keeping any with media, links or a quoted tweet.")
+	flagset.BoolVar(&cfg.retention.onlyZeroEngagement, "only-zero-engagement", false, "Only delete tweets nobody favorited or retweeted.")
+	flagset.BoolVar(&cfg.retention.keepSensitive, "keep-sensitive", false, "Keep tweets marked as possibly sensitive.")
+	flagset.BoolVar(&cfg.thinPerDay, "thin-keep-first-per-day", false, "Keep one tweet per day that would otherwise be deleted, in -timezone days: the day's latest, or its earliest with -delete-order=oldest.")
+	flagset.Float64Var(&cfg.retention.keepRatio, "keep-ratio", 0, "Fraction (0-1) of old tweets to keep, picked the same way on every run.")
+	flagset.BoolVar(&cfg.validateKeepIDs, "validate-keep-ids", false, "Report keep IDs that never matched a tweet or favorite during the run.")
+	cfg.gapWarning = year
+	flagset.Var((*retentionDuration)(&cfg.gapWarning), "gap-warning", "Warn when consecutive pages of the timeline are further apart in time than this. Zero disables the check.")
+	flagset.IntVar(&cfg.pageSize, "page-size", maxPageSize, "Number of tweets to fetch per request, up to 200.")
+	flagset.BoolVar(&cfg.useSearch, "use-search", false, "Also search for the account's tweets to reach past the timeline limit.")
+	flagset.IntVar(&cfg.retention.minLength, "keep-min-length", 0, "Keep tweets with at least this many characters. Zero disables the rule.")
+	flagset.BoolVar(&cfg.retention.expandURLs, "expand-urls", false, "Match keywords against the expanded form of t.co links.")
+	flagset.BoolVar(&cfg.dryRun, "dry-run", false, "Log what would be deleted without deleting anything.")
+	flagset.BoolVar(&cfg.yes, "yes", false, "Proceed without the safety confirmation for aggressive policies.")
+	flagset.StringVar(&cfg.deletedIDsFile, "deleted-ids-file", "", "File to append the IDs of deleted tweets and favorites to, one per line.")
+	flagset.StringVar(&cfg.stateFile, "state-file", "", "File to record progress in, so an interrupted run resumes where it left off.")
+	cfg.checkpointInterval = checkpointInterval{pages: defaultCheckpointPages}
+	flagset.Var(&cfg.checkpointInterval, "checkpoint-interval", "How often to save progress to -state-file: a number of pages, or a duration such as \"30s\". Progress is always saved when a run stops early or finishes.")
+	flagset.BoolVar(&cfg.verifyBeforeDelete, "verify-before-delete", false, "With -archive, check which archived tweets still exist with statuses/lookup, 100 at a time, and skip the rest.")
+	flagset.StringVar(&cfg.archive, "archive", "", "Twitter data archive (the extracted directory or its tweets.js) to also prune tweets from, reaching past the timeline's most recent 3,200.")
+	flagset.BoolVar(&cfg.incremental, "incremental", false, "Stop scanning the timeline at tweets the last complete run already decided on. Requires -state-file.")
+	flagset.StringVar(&cfg.dumpTimeline, "dump-timeline", "", "Write the whole reachable timeline and favorites to this JSON Lines file for analysis, then stop. No rules are applied and nothing is deleted.")
+	flagset.BoolVar(&cfg.exportOnly, "export-only", false, "Write every tweet on the timeline to -backup-file and delete nothing.")
+	flagset.StringVar(&cfg.backupFile, "backup-file", "", "File to append each tweet to, as a line of JSON, before deleting it. Tweets already in the file aren't added again.")
+	flagset.StringVar(&cfg.errorMode, "error-mode", errorModeFailFast, "What to do when a tweet can't be processed: fail-fast stops the run, best-effort carries on and reports every error at the end.")
+	flagset.BoolVar(&continueOnError, "continue-on-error", false, "Shorthand for -error-mode=best-effort.")
+	flagset.StringVar(&cfg.failedFile, "failed-file", "", "File to append deletions that failed to. Retry them with \"tprune retry\".")
+	flagset.StringVar(&cfg.activeWindow, "active-window", "", "Only delete during this daily window in -timezone, like 02:00-05:00. A run outside it, or that reaches its end, stops early.")
+	flagset.StringVar(&cfg.controlFile, "control-file", "", "Pause deleting while this file exists, checking every few seconds, and resume once it's removed.")
+	flagset.IntVar(&cfg.confirmThreshold, "confirm-threshold", 0, "Refuse to run without -yes when more than this many tweets and favorites would be deleted. Zero disables the check.")
+	flagset.BoolVar(&cfg.countOnly, "count-only", false, "Print how many tweets and favorites would be deleted without deleting anything.")
+	flagset.StringVar(&keepIDs, "keep-ids", "", "Tweet IDs or status URLs to keep forever.")
 	flagset.StringVar(&keepKeywords, "keep-keywords", "", "Tweet keywords to keep forever.")
-	if err := flagset.Parse(os.Args[1:]); err != nil {
+	flagset.StringVar(&onlyIDs, "only-ids", "", "Tweet IDs or status URLs to delete. Everything else is kept. Other keep rules still apply.")
+	flagset.StringVar(&keepMentions, "keep-mentions", "", "Screen names whose mentions keep a tweet forever.")
+	flagset.StringVar(&cfg.retention.keepLogic, "keep-logic", keepAny, "How keep rules combine: any keeps a tweet matching any of them, all only one matching every rule in use. Keep IDs, ID ranges and -max-age always apply.")
+	flagset.StringVar(&keepIDRanges, "keep-id-ranges", "", "Comma-separated ranges of tweet IDs to keep forever, as min-max (inclusive).")
+	flagset.StringVar(&deleteIfNone, "delete-if-none-of", "", "Comma-separated substrings; only tweets containing none of them are deleted. Unlike -keep-keywords, this applies whatever -keep-logic is.")
+	flagset.StringVar(&matchQuery, "match-query", "", "Only delete tweets whose text satisfies this expression of words and \"quoted phrases\" combined with AND, OR, NOT and parentheses. Matching is case-insensitive.")
+	flagset.StringVar(&keepHashtags, "keep-hashtags", "", "Comma-separated hashtags whose tweets are kept forever. Only whole hashtags match, ignoring case.")
+	flagset.StringVar(&keepFromLists, "keep-from-list", "", "Comma-separated list IDs; your tweets in these lists' timelines are kept forever.")
+	flagset.StringVar(&keepRepliesTo, "keep-replies-to", "", "Screen names or user IDs whose replies from you are kept forever.")
+	flagset.IntVar(&cfg.sample, "sample", 0, "In a dry run, log the decisions for only a random sample of this many tweets and favorites.")
+	flagset.BoolVar(&cfg.redactText, "redact-text", false, "Shorten the tweet text logged by -sample to its first 20 characters. Only -sample is affected: -backup-file, -report-kept and -plan-html still get the full text.")
+	flagset.StringVar(&logSample, "log-sample", "", "Sample per-tweet logs on big runs, as \"initial,thereafter\": each second, log the first initial entries with the same message, then every thereafter-th. Warnings and errors are never sampled.")
+	flagset.StringVar(&cfg.keepIDsURL, "keep-ids-url", "", "URL of a list of tweet IDs to keep forever, added to -keep-ids. The list is a JSON array or one ID per line.")
+	flagset.StringVar(&cfg.policyURL, "policy-url", "", "URL of a JSON retention policy to fetch at startup. Its maxAge replaces -max-age, and its keepIDs and keepKeywords are added to the local ones.")
+	flagset.StringVar(&cfg.planHTML, "plan-html", "", "In a dry run, write an HTML report of what would be deleted, by month, to this file.")
+	flagset.StringVar(&cfg.reportKept, "report-kept", "", "In a dry run, write each tweet and favorite that's kept, with the rule keeping it, to this JSON Lines file.")
+	flagset.StringVar(&cfg.summaryFile, "summary-file", "", "File to write a summary of the run to.")
+	flagset.BoolVar(&cfg.reportByYear, "report-by-year", false, "Break down the tweets deleted and kept by the year they were posted in the run summary.")
+	flagset.StringVar(&cfg.summaryFmt, "summary-format", "", "Format of the run summary: text, json or yaml. When set, the summary is also printed to stdout. The summary file and webhook default to json.")
+	flagset.StringVar(&cfg.webhookURL, "webhook-url", "", "URL to POST a summary of the run to when it finishes.")
+	flagset.StringVar(&cfg.webhookSecret, "webhook-secret", "", "Secret to sign webhook requests with. The HMAC-SHA256 of the body is sent in the X-Tprune-Signature header.")
+	flagset.StringVar(&cfg.cpuProfile, "cpuprofile", "", "Write a CPU profile to this file.")
+	flagset.StringVar(&cfg.trace, "trace", "", "Write an execution trace to this file.")
+	hideFlags(flagset, "cpuprofile", "trace")
+	flagset.StringVar(&cfg.deleteOrder, "delete-order", orderNewest, "Order to delete in: newest, or oldest, which holds the whole timeline and favorites in memory first. With -thin-keep-first-per-day, each day's survivor is its first tweet in this order.")
+	flagset.IntVar(&cfg.inFlight, "in-flight", 1, "Maximum number of deletions in flight at once.")
+	flagset.StringVar(&strategy, "strategy", "delete", "How tweets are removed: delete, or reply-delete to reply with a \"[deleted <id>]\" marker first so threads show the gap.")
+	flagset.StringVar(&targetList, "targets", "tweets,favorites", "Comma-separated kinds of content to prune: tweets, favorites and dms.")
+	flagset.BoolVar(&tweetsOnly, "tweets-only", false, "Only prune tweets. Shorthand for -targets=tweets.")
+	flagset.BoolVar(&favesOnly, "favorites-only", false, "Only prune favorites. Shorthand for -targets=favorites.")
+	flagset.StringVar(&keepLangs, "keep-lang", "", "Tweet languages (BCP-47 codes) to keep forever. Languages are detected by Twitter and may be \"und\".")
+	if err := flagset.Parse(args); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := applyConfigFile(flagset, configPath, true); err != nil {
 		fmt.Println(err)
 		os.Exit(2)
 	}
 
 	// Build and validate configuration
+	if plan {
+		cfg.dryRun = true
+	}
 	int64KeepIDs, err := parseKeepIDs(keepIDs)
 	if err != nil {
 		fmt.Println(err)
@@ -45,116 +180,1422 @@ func main() {
 		os.Exit(2)
 	}
 	cfg.retention.ids = int64KeepIDs
+	int64OnlyIDs, err := parseKeepIDs(onlyIDs)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	cfg.retention.onlyIDs = int64OnlyIDs
+	cfg.keepFromLists, err = parseListIDs(keepFromLists)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	cfg.retention.idRanges, err = parseIDRanges(keepIDRanges)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	if matchQuery != "" {
+		cfg.retention.matchQuery, err = parseQuery(matchQuery)
+		if err != nil {
+			fmt.Println(err)
+			flagset.Usage()
+			os.Exit(2)
+		}
+	}
 	cfg.retention.keywords = parseKeepKeywords(keepKeywords)
+	cfg.retention.deleteIfNoneOf = parseKeepKeywords(deleteIfNone)
+	cfg.retention.langs = parseKeepLangs(keepLangs)
+	cfg.retention.mentions = parseScreenNames(keepMentions)
+	cfg.retention.hashtags = parseHashtags(keepHashtags)
+	cfg.retention.repliesToNames, cfg.retention.repliesToIDs = parseUsers(keepRepliesTo)
+	cfg.logSampleInitial, cfg.logSampleThereafter, err = parseLogSample(logSample)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	if tweetsOnly || favesOnly {
+		var explicitTargets bool
+		flagset.Visit(func(f *flag.Flag) {
+			explicitTargets = explicitTargets || f.Name == "targets"
+		})
+		switch {
+		case tweetsOnly && favesOnly:
+			fmt.Println("-tweets-only and -favorites-only cannot be used together")
+			flagset.Usage()
+			os.Exit(2)
+		case explicitTargets:
+			fmt.Println("-tweets-only and -favorites-only cannot be used with -targets")
+			flagset.Usage()
+			os.Exit(2)
+		case tweetsOnly:
+			targetList = "tweets"
+		case favesOnly:
+			targetList = "favorites"
+		}
+	}
+	if continueOnError {
+		var explicitMode bool
+		flagset.Visit(func(f *flag.Flag) {
+			explicitMode = explicitMode || f.Name == "error-mode"
+		})
+		if explicitMode && cfg.errorMode != errorModeBestEffort {
+			fmt.Printf("-continue-on-error cannot be used with -error-mode=%s\n", cfg.errorMode)
+			flagset.Usage()
+			os.Exit(2)
+		}
+		cfg.errorMode = errorModeBestEffort
+	}
+	cfg.targets, err = parseTargets(targetList)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	cfg.strategy, err = lookupStrategy(strategy)
+	if err != nil {
+		fmt.Println(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	if cfg.validateKeepIDs {
+		cfg.retention.matchedIDs = map[int64]bool{}
+	}
+	cfg.planPolicy = planPolicy(flagset)
+	if err := cfg.readSecretFiles(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 	if err := cfg.validate(); err != nil {
+		printErrors(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+
+	return run(cfg)
+}
+
+// verifyCommand checks the credentials and reports which account they belong
+// to.
+func verifyCommand(name string, args []string) error {
+	var (
+		cfg        config
+		configPath string
+	)
+	flagset := flag.NewFlagSet("tprune "+name, flag.ExitOnError)
+	cfg.registerCommonFlags(flagset, &configPath)
+	if err := flagset.Parse(args); err != nil {
 		fmt.Println(err)
+		os.Exit(2)
+	}
+	// The config file also holds prune's settings, which verify has no flags for
+	if err := applyConfigFile(flagset, configPath, false); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.validateCommon(); err != nil {
+		printErrors(err)
 		flagset.Usage()
 		os.Exit(2)
 	}
 
-	// Do it to it, Lars!
-	if err := run(cfg); err != nil {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to setup http client: %w", err)
+	}
+	account, err := verifyCredentials(twitter.NewClient(httpClient))
+	if err != nil {
+		return err
+	}
+	protected := ""
+	if account.Protected {
+		protected = ", protected"
+	}
+	fmt.Printf("Credentials are valid for @%s (%s%s)\n", account.ScreenName, account.IDStr, protected)
+	return nil
+}
+
+// retryCommand re-attempts the deletions recorded in a failed file without
+// scanning the timeline again. Deletions that fail again are kept in the file.
+func retryCommand(name string, args []string) error {
+	var (
+		cfg        config
+		configPath string
+	)
+	flagset := flag.NewFlagSet("tprune "+name, flag.ExitOnError)
+	cfg.registerCommonFlags(flagset, &configPath)
+	flagset.StringVar(&cfg.failedFile, "failed-file", "", "File of failed deletions written by prune.")
+	flagset.StringVar(&cfg.deletedIDsFile, "deleted-ids-file", "", "File to append the IDs of deleted tweets and favorites to, one per line.")
+	flagset.BoolVar(&cfg.dryRun, "dry-run", false, "Log what would be retried without deleting anything.")
+	if err := flagset.Parse(args); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(2)
+	}
+	if err := applyConfigFile(flagset, configPath, false); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if err := cfg.validateCommon(); err != nil {
+		printErrors(err)
+		flagset.Usage()
+		os.Exit(2)
+	}
+	if cfg.failedFile == "" {
+		fmt.Println("-failed-file is required")
+		flagset.Usage()
+		os.Exit(2)
+	}
+
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to setup logger: %w", err)
+	}
+	defer logger.Sync()
+
+	failures, err := readFailedFile(cfg.failedFile)
+	if err != nil {
+		return fmt.Errorf("failed to read failed file: %w", err)
+	}
+
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to setup http client: %w", err)
 	}
+	destroyer := newDestroyer(twitter.NewClient(httpClient), retention{}, time.Now(), cfg.dryRun)
+	if cfg.deletedIDsFile != "" && !cfg.dryRun {
+		deletedIDs, err := openIDFile(cfg.deletedIDsFile)
+		if err != nil {
+			return fmt.Errorf("failed to open deleted IDs file: %w", err)
+		}
+		defer deletedIDs.Close()
+		destroyer.deletedIDs = deletedIDs
+	}
+
+	// Failures that happen again are written to a new file which replaces the
+	// old one once every failure has been retried.
+	remainingPath := cfg.failedFile + ".retry"
+	if !cfg.dryRun {
+		if err := os.Remove(remainingPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale failed file: %w", err)
+		}
+		remaining, err := openFailedFile(remainingPath)
+		if err != nil {
+			return fmt.Errorf("failed to open failed file: %w", err)
+		}
+		defer remaining.Close()
+		destroyer.failed = remaining
+	}
+
+	var failed int
+	for _, f := range failures {
+		if err := destroyer.retry(logger, f); err != nil {
+			if errors.Is(err, errAuth) {
+				return err
+			}
+			logger.Warn("Retry failed", zap.Int64("id", f.id), zap.Error(err))
+			failed++
+		}
+	}
+
+	logger.Info("Finished", append(destroyer.stats.fields(), zap.Int("failed", failed))...)
+	if cfg.dryRun {
+		return nil
+	}
+	if err := destroyer.failed.Close(); err != nil {
+		return fmt.Errorf("failed to write failed file: %w", err)
+	}
+	return os.Rename(remainingPath, cfg.failedFile)
+}
+
+// hideFlags leaves the named flags out of the usage message. They're for
+// development rather than everyday use.
+func hideFlags(flagset *flag.FlagSet, names ...string) {
+	hidden := map[string]bool{}
+	for _, name := range names {
+		hidden[name] = true
+	}
+	flagset.Usage = func() {
+		visible := flag.NewFlagSet(flagset.Name(), flag.ContinueOnError)
+		visible.SetOutput(flagset.Output())
+		flagset.VisitAll(func(f *flag.Flag) {
+			if hidden[f.Name] {
+				return
+			}
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		})
+		fmt.Fprintf(flagset.Output(), "Usage of %s:\n", flagset.Name())
+		visible.PrintDefaults()
+	}
+}
+
+// registerCommonFlags registers the credential, connection and logging flags
+// shared by every command.
+func (cfg *config) registerCommonFlags(flagset *flag.FlagSet, configPath *string) {
+	flagset.StringVar(configPath, "config", "", "Config file. Defaults to $XDG_CONFIG_HOME/tprune/config.yaml when present.")
+	flagset.StringVar(&cfg.consumerKey, "consumer-key", "", "Twitter Consumer Key")
+	flagset.StringVar(&cfg.consumerSecret, "consumer-secret", "", "Twitter Consumer Secret")
+	flagset.StringVar(&cfg.oauthToken, "oauth-token", "", "Twitter OAuth Token")
+	flagset.StringVar(&cfg.oauthTokenSecret, "oauth-token-secret", "", "Twitter OAuth Token Secret")
+	flagset.StringVar(&cfg.consumerKeyFile, "consumer-key-file", "", "File to read the Twitter Consumer Key from, if -consumer-key isn't set.")
+	flagset.StringVar(&cfg.consumerSecretFile, "consumer-secret-file", "", "File to read the Twitter Consumer Secret from, if -consumer-secret isn't set.")
+	flagset.StringVar(&cfg.oauthTokenFile, "oauth-token-file", "", "File to read the Twitter OAuth Token from, if -oauth-token isn't set.")
+	flagset.StringVar(&cfg.oauthTokenSecretFile, "oauth-token-secret-file", "", "File to read the Twitter OAuth Token Secret from, if -oauth-token-secret isn't set.")
+	flagset.StringVar(&cfg.logLevel, "log-level", "info", "Log level")
+	flagset.BoolVar(&cfg.noColor, "no-color", false, "Don't colorize log levels. Colors are only used when logging to a terminal, and not when NO_COLOR is set.")
+	flagset.StringVar(&cfg.timezone, "timezone", "UTC", "Timezone (IANA name) to display times in. Ages are unaffected.")
+	flagset.DurationVar(&cfg.httpTimeout, "http-timeout", 30*time.Second, "Timeout for each request to Twitter. Zero disables the timeout.")
+	flagset.StringVar(&cfg.proxy, "proxy", "", "HTTP proxy URL. Defaults to the HTTPS_PROXY environment variable.")
+	flagset.StringVar(&cfg.userAgent, "user-agent", "tprune/"+version, "User-Agent header to send to Twitter.")
+	flagset.StringVar(&cfg.apiBaseURL, "api-base-url", "", "Base URL to send Twitter API requests to instead of https://api.twitter.com, such as an API gateway.")
 }
 
 type config struct {
 	username                     string
 	consumerKey, consumerSecret  string
 	oauthToken, oauthTokenSecret string
+	consumerKeyFile              string
+	consumerSecretFile           string
+	oauthTokenFile               string
+	oauthTokenSecretFile         string
+	secretsFromFiles             []string
 	retention                    retention
 	logLevel                     string
+	noColor                      bool
+	timezone                     string
+	dryRun                       bool
+	yes                          bool
+	confirmThreshold             int
+	deletedIDsFile               string
+	backupFile                   string
+	archive                      string
+	incremental                  bool
+	exportOnly                   bool
+	stateFile                    string
+	checkpointInterval           checkpointInterval
+	failedFile                   string
+	thinPerDay                   bool
+	sample                       int
+	summaryFile                  string
+	summaryFmt                   string
+	webhookURL                   string
+	webhookSecret                string
+	cpuProfile                   string
+	trace                        string
+	proxy                        string
+	apiBaseURL                   string
+	userAgent                    string
+	httpTimeout                  time.Duration
+	validateKeepIDs              bool
+	useSearch                    bool
+	countOnly                    bool
+	pageSize                     int
+	targets                      targets
+	strategy                     removalStrategy
+	keepPinned                   bool
+	keepVerifiedReplies          bool
+	keepFirstTweet               bool
+	keepTopN                     int
+	reportByYear                 bool
+	skipVerify                   bool
+	accountID                    int64
+	reportKept                   string
+	planHTML                     string
+	controlFile                  string
+	policyURL                    string
+	keepIDsURL                   string
+	verifyBeforeDelete           bool
+	keepFromLists                []int64
+	activeWindow                 string
+	redactText                   bool
+	dumpTimeline                 string
+	planPolicy                   []planSetting
+	inFlight                     int
+	deleteOrder                  string
+	errorMode                    string
+	gapWarning                   time.Duration
+	logSampleInitial             int
+	logSampleThereafter          int
 }
 
+// maxPageSize is the most tweets the timeline and favorites endpoints return
+// per request
+const maxPageSize = 200
+
+// Error modes
+const (
+	errorModeFailFast   = "fail-fast"
+	errorModeBestEffort = "best-effort"
+)
+
+// Deletion orders
+const (
+	orderNewest = "newest"
+	orderOldest = "oldest"
+)
+
+// minSafeMaxAge is the smallest -max-age accepted without -yes. Anything lower
+// deletes nearly an entire account's history on its first run.
+const minSafeMaxAge = 24 * time.Hour
+
 func (cfg config) validate() error {
-	if cfg.username == "" {
-		return fmt.Errorf("-username is required")
+	missing := cfg.missingCommon()
+	if cfg.retention.maxAge == 0 && cfg.policyURL == "" && cfg.dumpTimeline == "" && !cfg.exportOnly {
+		missing = append(missing, "-max-age")
 	}
-	if cfg.consumerKey == "" {
-		return fmt.Errorf("-consumer-key is required")
+	if cfg.skipVerify && cfg.username == "" {
+		missing = append(missing, "-username")
 	}
-	if cfg.consumerSecret == "" {
-		return fmt.Errorf("-consumer-secret is required")
+	if cfg.skipVerify && cfg.accountID == 0 {
+		missing = append(missing, "-account-id")
 	}
-	if cfg.oauthToken == "" {
-		return fmt.Errorf("-oauth-token is required")
+	errs := multierr.Combine(missingError(missing), cfg.checkCommon())
+	if cfg.accountID < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-account-id must be positive"))
 	}
-	if cfg.oauthTokenSecret == "" {
-		return fmt.Errorf("-oauth-token-secret is required")
+	if cfg.retention.maxAge < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-max-age must not be negative"))
 	}
-	if cfg.retention.maxAge == 0 {
-		return fmt.Errorf("-max-age is required")
+	if cfg.retention.mediaMaxAge < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-media-max-age must not be negative"))
 	}
-	return nil
+	if cfg.retention.maxAge > 0 && cfg.retention.maxAge < minSafeMaxAge && !cfg.dryRun && !cfg.countOnly && !cfg.yes {
+		errs = multierr.Append(errs, fmt.Errorf("-max-age of %s is below %s and will delete almost everything; pass -yes to proceed or -dry-run to preview", cfg.retention.maxAge, minSafeMaxAge))
+	}
+	if cfg.retention.mediaMaxAge > 0 && cfg.retention.mediaMaxAge < minSafeMaxAge && !cfg.dryRun && !cfg.countOnly && !cfg.yes {
+		errs = multierr.Append(errs, fmt.Errorf("-media-max-age of %s is below %s and will delete almost every tweet with media; pass -yes to proceed or -dry-run to preview", cfg.retention.mediaMaxAge, minSafeMaxAge))
+	}
+	if len(cfg.retention.onlyIDs) > 0 && len(cfg.retention.ids) > 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-only-ids and -keep-ids cannot be used together"))
+	}
+	if cfg.retention.minLength < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-keep-min-length must not be negative"))
+	}
+	if cfg.retention.keepRatio < 0 || cfg.retention.keepRatio > 1 {
+		errs = multierr.Append(errs, fmt.Errorf("-keep-ratio must be between 0 and 1"))
+	}
+	if cfg.keepTopN < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-keep-top-n must not be negative"))
+	}
+	if cfg.confirmThreshold < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-confirm-threshold must not be negative"))
+	}
+	if cfg.webhookURL != "" {
+		if u, err := url.Parse(cfg.webhookURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = multierr.Append(errs, fmt.Errorf("-webhook-url must be an absolute URL"))
+		}
+	}
+	if cfg.policyURL != "" {
+		if u, err := url.Parse(cfg.policyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = multierr.Append(errs, fmt.Errorf("-policy-url must be an absolute URL"))
+		}
+	}
+	if cfg.keepIDsURL != "" {
+		if u, err := url.Parse(cfg.keepIDsURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = multierr.Append(errs, fmt.Errorf("-keep-ids-url must be an absolute URL"))
+		}
+	}
+	if _, ok := summaryContentTypes[cfg.summaryFormat()]; !ok {
+		errs = multierr.Append(errs, fmt.Errorf("-summary-format must be text, json or yaml"))
+	}
+	if cfg.sample < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-sample must not be negative"))
+	}
+	if cfg.sample > 0 && !cfg.dryRun {
+		errs = multierr.Append(errs, fmt.Errorf("-sample requires -dry-run or the plan command"))
+	}
+	if cfg.reportKept != "" && !cfg.dryRun {
+		errs = multierr.Append(errs, fmt.Errorf("-report-kept requires -dry-run or the plan command"))
+	}
+	if cfg.planHTML != "" && !cfg.dryRun {
+		errs = multierr.Append(errs, fmt.Errorf("-plan-html requires -dry-run or the plan command"))
+	}
+	if cfg.retention.keepLogic != keepAny && cfg.retention.keepLogic != keepAll {
+		errs = multierr.Append(errs, fmt.Errorf("-keep-logic must be %s or %s", keepAny, keepAll))
+	}
+	if cfg.errorMode != errorModeFailFast && cfg.errorMode != errorModeBestEffort {
+		errs = multierr.Append(errs, fmt.Errorf("-error-mode must be %s or %s", errorModeFailFast, errorModeBestEffort))
+	}
+	if cfg.deleteOrder != orderNewest && cfg.deleteOrder != orderOldest {
+		errs = multierr.Append(errs, fmt.Errorf("-delete-order must be %s or %s", orderNewest, orderOldest))
+	}
+	if cfg.exportOnly && cfg.backupFile == "" {
+		errs = multierr.Append(errs, fmt.Errorf("-export-only requires -backup-file"))
+	}
+	if cfg.verifyBeforeDelete && cfg.archive == "" {
+		errs = multierr.Append(errs, fmt.Errorf("-verify-before-delete requires -archive"))
+	}
+	if cfg.incremental && cfg.stateFile == "" {
+		errs = multierr.Append(errs, fmt.Errorf("-incremental requires -state-file"))
+	}
+	if cfg.stateFile != "" && cfg.deleteOrder == orderOldest {
+		errs = multierr.Append(errs, fmt.Errorf("-state-file cannot be used with -delete-order=%s", orderOldest))
+	}
+	if cfg.activeWindow != "" {
+		if _, err := parseActiveWindow(cfg.activeWindow, time.UTC); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	if cfg.gapWarning < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-gap-warning must not be negative"))
+	}
+	if cfg.inFlight < 1 {
+		errs = multierr.Append(errs, fmt.Errorf("-in-flight must be at least 1"))
+	}
+	if cfg.pageSize < 1 || cfg.pageSize > maxPageSize {
+		errs = multierr.Append(errs, fmt.Errorf("-page-size must be between 1 and %d", maxPageSize))
+	}
+	return errs
+}
+
+// printErrors prints each of the errors combined in err on its own line
+func printErrors(err error) {
+	for _, err := range multierr.Errors(err) {
+		fmt.Println(err)
+	}
+}
+
+// logText returns a tweet's text as -sample should log it. Files such as the
+// backup, kept report and plan get the full text regardless.
+func (cfg config) logText(text string) string {
+	if !cfg.redactText {
+		return text
+	}
+	return redactText(text)
+}
+
+// summaryFormat returns the format for the summary file and webhook
+func (cfg config) summaryFormat() string {
+	if cfg.summaryFmt == "" {
+		return summaryJSON
+	}
+	return cfg.summaryFmt
+}
+
+// validateCommon validates the flags shared by every command. Every problem
+// is reported, not just the first.
+func (cfg config) validateCommon() error {
+	return multierr.Combine(missingError(cfg.missingCommon()), cfg.checkCommon())
+}
+
+// missingCommon returns the required flags shared by every command that
+// weren't given
+func (cfg config) missingCommon() []string {
+	var missing []string
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"-consumer-key", cfg.consumerKey},
+		{"-consumer-secret", cfg.consumerSecret},
+		{"-oauth-token", cfg.oauthToken},
+		{"-oauth-token-secret", cfg.oauthTokenSecret},
+	} {
+		if f.value == "" {
+			missing = append(missing, f.name)
+		}
+	}
+	return missing
+}
+
+// missingError returns an error listing the missing flags, or nil if there
+// are none
+func missingError(missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("missing: %s", strings.Join(missing, ", "))
+}
+
+// checkCommon checks the values of the flags shared by every command
+func (cfg config) checkCommon() error {
+	var errs error
+	if _, err := time.LoadLocation(cfg.timezone); err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("-timezone is invalid: %w", err))
+	}
+	if cfg.httpTimeout < 0 {
+		errs = multierr.Append(errs, fmt.Errorf("-http-timeout must not be negative"))
+	}
+	if cfg.proxy != "" {
+		if _, err := url.Parse(cfg.proxy); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("-proxy is invalid: %w", err))
+		}
+	}
+	if cfg.apiBaseURL != "" {
+		if u, err := url.Parse(cfg.apiBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = multierr.Append(errs, fmt.Errorf("-api-base-url must be an absolute URL"))
+		}
+	}
+	return errs
 }
 
 func run(cfg config) error {
-	logger, err := newLogger(cfg.logLevel)
+	if cfg.cpuProfile != "" {
+		f, err := os.Create(cfg.cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if cfg.trace != "" {
+		f, err := os.Create(cfg.trace)
+		if err != nil {
+			return fmt.Errorf("failed to create trace: %w", err)
+		}
+		defer f.Close()
+		if err := trace.Start(f); err != nil {
+			return fmt.Errorf("failed to start trace: %w", err)
+		}
+		defer trace.Stop()
+	}
+
+	logger, err := newLogger(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to setup logger: %w", err)
 	}
 	defer logger.Sync()
 
+	runID, err := newRunID()
+	if err != nil {
+		return fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	logger = logger.With(zap.String("run_id", runID))
+	logger.Info("Starting",
+		zap.String("version", version),
+		zap.String("commit", commit),
+		zap.String("go", runtime.Version()))
+
+	sd := newShutdown()
+	defer sd.release()
+
 	var (
-		config     = oauth1.NewConfig(cfg.consumerKey, cfg.consumerSecret)
-		token      = oauth1.NewToken(cfg.oauthToken, cfg.oauthTokenSecret)
-		httpClient = config.Client(context.Background(), token)
-		client     = twitter.NewClient(httpClient)
+		startedAt = time.Now()
+		st        = &stats{}
 	)
+	err = prune(sd.stop, cfg, logger, st)
+	if errors.Is(err, errOutsideWindow) {
+		logger.Info("Stopping: outside active window", zap.String("active_window", cfg.activeWindow))
+		err = nil
+	}
+	if errors.Is(err, errInterrupted) {
+		logger.Warn("Stopped early: interrupted", st.fields()...)
+	}
+	if err == nil || st.errors > 0 && cfg.errorMode == errorModeBestEffort {
+		logger.Info("Finished", st.fields()...)
+	}
+
+	if cfg.summaryFile != "" || cfg.webhookURL != "" || cfg.summaryFmt != "" {
+		sum := newSummary(runID, startedAt, cfg.dryRun, st, err)
+		if cfg.summaryFmt != "" {
+			if err := sum.render(cfg.summaryFmt, os.Stdout); err != nil {
+				logger.Error("Failed to print summary", zap.Error(err))
+			}
+		}
+		if cfg.summaryFile != "" {
+			if err := sum.writeFile(cfg.summaryFile, cfg.summaryFormat()); err != nil {
+				logger.Error("Failed to write summary file", zap.Error(err))
+			}
+		}
+		if cfg.webhookURL != "" {
+			// A run that was asked to stop still reports how far it got,
+			// unless asked again
+			if err := postWebhook(sd.abort, cfg, sum); err != nil {
+				logger.Error("Failed to post summary to webhook", zap.Error(err))
+			}
+		}
+	}
+	return err
+}
 
-	account, _, err := client.Accounts.VerifyCredentials(nil)
+// prune deletes tweets and favorites according to the retention policy,
+// tallying what it did in st. The policy and keep IDs are fetched with ctx,
+// and once it's done, the run stops between tweets with errInterrupted.
+func prune(ctx context.Context, cfg config, logger *zap.Logger, st *stats) error {
+	if cfg.policyURL != "" {
+		pol, err := fetchPolicy(ctx, cfg)
+		switch {
+		case err != nil && cfg.retention.maxAge == 0:
+			// Without a local policy there's nothing safe to fall back to
+			return fmt.Errorf("failed to fetch policy: %w", err)
+		case err != nil:
+			logger.Warn("Failed to fetch policy; using the local one", zap.Error(err))
+		default:
+			if err := pol.apply(&cfg.retention); err != nil {
+				return fmt.Errorf("failed to apply policy from %s: %w", cfg.policyURL, err)
+			}
+			cfg.planPolicy = pol.planSettings(cfg.planPolicy)
+			logger.Info("Applied policy",
+				zap.String("url", cfg.policyURL),
+				zap.Duration("max_age", cfg.retention.maxAge),
+				zap.Int("keep_ids", len(pol.KeepIDs)),
+				zap.Int("keep_keywords", len(pol.KeepKeywords)))
+		}
+		if cfg.retention.maxAge == 0 {
+			return fmt.Errorf("policy from %s has no maxAge and -max-age isn't set", cfg.policyURL)
+		}
+		if cfg.retention.maxAge < minSafeMaxAge && !cfg.dryRun && !cfg.countOnly && !cfg.yes {
+			return fmt.Errorf("policy max age of %s is below %s and will delete almost everything; pass -yes to proceed or -dry-run to preview", cfg.retention.maxAge, minSafeMaxAge)
+		}
+	}
+
+	if cfg.keepIDsURL != "" {
+		// Deleting tweets the list was meant to protect is worse than not
+		// running, so there's no falling back
+		ids, err := fetchKeepIDs(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("failed to fetch keep IDs: %w", err)
+		}
+		logger.Info("Fetched keep IDs", zap.String("url", cfg.keepIDsURL), zap.Int("ids", len(ids)))
+		cfg.retention.ids = append(cfg.retention.ids, ids...)
+	}
+
+	var window *activeWindow
+	if cfg.activeWindow != "" {
+		loc, err := time.LoadLocation(cfg.timezone)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone: %w", err)
+		}
+		window, err = parseActiveWindow(cfg.activeWindow, loc)
+		if err != nil {
+			return err
+		}
+		if !cfg.dryRun && !window.contains(time.Now()) {
+			return errOutsideWindow
+		}
+	}
+
+	httpClient, err := newHTTPClient(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to verify credentials: %w", err)
+		return fmt.Errorf("failed to setup http client: %w", err)
+	}
+	client := twitter.NewClient(httpClient)
+
+	var account *twitter.User
+	if cfg.skipVerify || cfg.accountID != 0 && cfg.username != "" {
+		// Nothing is known about the account but what we're told, so it's
+		// taken to be unprotected
+		account = &twitter.User{
+			ID:         cfg.accountID,
+			IDStr:      strconv.FormatInt(cfg.accountID, 10),
+			ScreenName: cfg.username,
+		}
+		logger.Info("Skipping credential check: account given by -username and -account-id",
+			zap.String("id", account.IDStr),
+			zap.String("username", account.ScreenName))
+	} else {
+		account, err = verifyCredentials(client)
+		if err != nil {
+			return err
+		}
+		logger.Info("Verified credentials",
+			zap.String("id", account.IDStr),
+			zap.String("username", account.ScreenName),
+			zap.Bool("protected", account.Protected))
+	}
+	if cfg.username == "" {
+		cfg.username = account.ScreenName
+	}
+	cfg.retention.accountID = account.ID
+	if !strings.EqualFold(cfg.username, account.ScreenName) {
+		return fmt.Errorf("-username is @%s but the credentials are for @%s", cfg.username, account.ScreenName)
+	}
+	if cfg.dumpTimeline != "" {
+		return dumpTimeline(logger, client, account, cfg)
+	}
+	// Nothing is kept or deleted, so the passes finding what to keep are
+	// skipped
+	if cfg.exportOnly {
+		return exportTweets(logger, client, account, cfg)
+	}
+	// The timeline and favorites of a protected account are readable with its
+	// own credentials, but search never returns protected tweets
+	if account.Protected && cfg.useSearch {
+		logger.Warn("Skipping -use-search: search doesn't cover protected accounts")
+		cfg.useSearch = false
+	}
+	if cfg.keepPinned {
+		// The v1.1 account has no pinned tweet, and apps and servers
+		// without v2 access shouldn't be kept from running
+		pinnedID, err := lookupPinnedTweet(httpClient, account.IDStr)
+		if err != nil {
+			logger.Warn("Failed to look up pinned tweet; it's only kept if the rules keep it (pass -keep-pinned=false to skip the lookup)", zap.Error(err))
+		}
+		if pinnedID != 0 {
+			logger.Info("Protecting pinned tweet", zap.Int64("id", pinnedID))
+			cfg.retention.ids = append(cfg.retention.ids, pinnedID)
+		}
 	}
-	logger.Info("Verified credentials",
-		zap.String("id", account.IDStr),
-		zap.String("username", account.ScreenName))
 
-	tweetFetcher := newTweetFetcher(client, account.ScreenName)
-	favoriteFetcher := newFavoriteFetcher(client, account.ID)
-	destroyer := newDestroyer(client, cfg.retention)
+	var archived []archiveTweet
+	if cfg.targets.tweets && cfg.archive != "" {
+		archived, err = readArchive(cfg.archive)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		logger.Info("Read archive", zap.Int("tweets", len(archived)))
+	}
+	if cfg.keepFirstTweet {
+		// Only an archive is sure to reach back to the first tweet
+		var firstID int64
+		if archived != nil {
+			firstID = firstArchivedTweet(archived)
+		} else {
+			firstID, err = oldestReachableTweet(client, account.ScreenName, cfg.pageSize)
+			if err != nil {
+				return fmt.Errorf("failed to find first tweet: %w", err)
+			}
+		}
+		if firstID != 0 {
+			logger.Info("Protecting first tweet", zap.Int64("id", firstID), zap.Bool("from_archive", archived != nil))
+			cfg.retention.ids = append(cfg.retention.ids, firstID)
+		}
+	}
 
-	for tweetFetcher.fetch() {
-		if tweetFetcher.err != nil {
-			return fmt.Errorf("failed to fetch: %w", tweetFetcher.err)
+	if cfg.targets.tweets && cfg.keepTopN > 0 {
+		// The archive reaches tweets the timeline doesn't
+		var topIDs []int64
+		if archived != nil {
+			topIDs, err = topArchivedTweets(client, archived, cfg.keepTopN, cfg.retention, time.Now())
+			if err != nil {
+				return fmt.Errorf("failed to rank tweets: %w", err)
+			}
+		} else {
+			topIDs, err = topTimelineTweets(client, account.ScreenName, cfg.pageSize, cfg.keepTopN, cfg.retention, time.Now())
+			if err != nil {
+				return fmt.Errorf("failed to rank tweets: %w", err)
+			}
+		}
+		logger.Info("Protecting most favorited tweets", zap.Int("tweets", len(topIDs)), zap.Bool("from_archive", archived != nil))
+		cfg.retention.ids = append(cfg.retention.ids, topIDs...)
+	}
+
+	for _, listID := range cfg.keepFromLists {
+		ids, err := listTweetIDs(client, listID, account.ID, cfg.pageSize)
+		if err != nil {
+			return err
+		}
+		logger.Info("Protecting tweets in list", zap.Int64("list_id", listID), zap.Int("tweets", len(ids)))
+		cfg.retention.ids = append(cfg.retention.ids, ids...)
+	}
+
+	var verified *verifiedCache
+	if cfg.keepVerifiedReplies {
+		verified = newVerifiedCache(client)
+		cfg.retention.verifiedReplies = verified
+	}
+
+	now := time.Now()
+	if cfg.countOnly {
+		tweets, favorites, err := countTombstoned(ctx, cfg, logger, client, account, archived, verified, now)
+		if err != nil {
+			return fmt.Errorf("failed to count: %w", err)
+		}
+		fmt.Printf("Tweets to delete: %d\nFavorites to remove: %d\n", tweets, favorites)
+		return nil
+	}
+	if cfg.confirmThreshold > 0 && !cfg.dryRun && !cfg.yes {
+		tweets, favorites, err := countTombstoned(ctx, cfg, logger, client, account, archived, verified, now)
+		if err != nil {
+			return fmt.Errorf("failed to count: %w", err)
+		}
+		if count := tweets + favorites; count > cfg.confirmThreshold {
+			return fmt.Errorf("policy would delete %d tweets and remove %d favorites, more than -confirm-threshold=%d together; pass -yes to proceed or -dry-run to preview", tweets, favorites, cfg.confirmThreshold)
+		}
+	}
+	return sweep(ctx, cfg, logger, client, account, archived, verified, window, now, st)
+}
+
+// sweep goes through the account's tweets, from the timeline, search and the
+// archive, then its favorites and messages, deleting what the retention rules
+// evict as of now and tallying it in st. archived is the archive's tweets, if
+// there is one, and verified the cache behind -keep-verified-replies, if it's
+// set.
+func sweep(ctx context.Context, cfg config, logger *zap.Logger, client *twitter.Client, account *twitter.User, archived []archiveTweet, verified *verifiedCache, window *activeWindow, now time.Time, st *stats) error {
+	var err error
+	if rules := cfg.retention.fullUserRules(); len(rules) > 0 {
+		logger.Warn("Fetching tweets with full user objects instead of trimming them, which makes every page larger",
+			zap.Strings("rules", rules))
+	}
+	tweetFetcher := newTweetFetcher(client, account.ScreenName, cfg.pageSize, !cfg.retention.needsFullUser())
+	favoriteFetcher := newFavoriteFetcher(client, account.ID, cfg.pageSize)
+	destroyer := newDestroyer(client, cfg.retention, now, cfg.dryRun)
+	destroyer.stats = st
+	if cfg.deletedIDsFile != "" && !cfg.dryRun {
+		deletedIDs, err := openIDFile(cfg.deletedIDsFile)
+		if err != nil {
+			return fmt.Errorf("failed to open deleted IDs file: %w", err)
+		}
+		defer deletedIDs.Close()
+		destroyer.deletedIDs = deletedIDs
+	}
+	if cfg.backupFile != "" && !cfg.dryRun {
+		backup, err := openBackupFile(cfg.backupFile)
+		if err != nil {
+			return fmt.Errorf("failed to open backup file: %w", err)
+		}
+		defer backup.Close()
+		destroyer.backup = backup
+	}
+	if cfg.failedFile != "" && !cfg.dryRun {
+		failed, err := openFailedFile(cfg.failedFile)
+		if err != nil {
+			return fmt.Errorf("failed to open failed file: %w", err)
+		}
+		defer failed.Close()
+		destroyer.failed = failed
+	}
+	if cfg.reportByYear {
+		loc, err := time.LoadLocation(cfg.timezone)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone: %w", err)
+		}
+		destroyer.byYear = loc
+	}
+	destroyer.controlFile = cfg.controlFile
+	destroyer.window = window
+	destroyer.stop = ctx.Done()
+	var collected error
+	if cfg.errorMode == errorModeBestEffort {
+		destroyer.collected = &collected
+	}
+	destroyer.strategy = cfg.strategy
+	if cfg.inFlight > 1 {
+		destroyer.pool = newDeletePool(cfg.inFlight)
+		// Wait for in-flight deletions before the files they record to are
+		// closed
+		defer destroyer.wait()
+	}
+	if cfg.sample > 0 {
+		destroyer.sample = newReservoir(cfg.sample)
+	}
+	if cfg.planHTML != "" {
+		loc, err := time.LoadLocation(cfg.timezone)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone: %w", err)
+		}
+		destroyer.plan = newPlanReport(cfg.planPolicy, loc)
+	}
+	if cfg.reportKept != "" {
+		kept, err := createKeptReport(cfg.reportKept)
+		if err != nil {
+			return fmt.Errorf("failed to create kept report: %w", err)
+		}
+		defer kept.Close()
+		destroyer.kept = kept
+	}
+	if cfg.thinPerDay {
+		loc, err := time.LoadLocation(cfg.timezone)
+		if err != nil {
+			return fmt.Errorf("failed to load timezone: %w", err)
+		}
+		destroyer.thinning = newThinning(loc)
+	}
+
+	// An interrupted run picks up from the last page it saved
+	var state runState
+	if cfg.stateFile != "" {
+		state, err = loadState(cfg.stateFile)
+		if err != nil {
+			return fmt.Errorf("failed to load state file: %w", err)
+		}
+		if !state.isFresh() {
+			logger.Info("Resuming interrupted run",
+				zap.Int64("tweet_max_id", state.TweetMaxID),
+				zap.Int64("favorite_max_id", state.FavoriteMaxID),
+				zap.Bool("completed_tweets", state.CompletedTweets),
+				zap.Bool("completed_favorites", state.CompletedFavorites))
+		}
+		tweetFetcher.maxID = state.TweetMaxID
+		favoriteFetcher.maxID = state.FavoriteMaxID
+	}
+
+	// Tweets that were already old enough to delete when the last complete
+	// run started were decided then, so an incremental run stops short of
+	// them.
+	var cutoff time.Time
+	if cfg.incremental && !state.LastCompletedAt.IsZero() {
+		cutoff = state.LastCompletedAt.Add(-cfg.retention.longestMaxAge())
+		logger.Info("Only scanning tweets newer than the last run covered",
+			zap.Time("last_completed_at", state.LastCompletedAt),
+			zap.Time("cutoff", cutoff))
+	}
+	var (
+		unsavedPages   int
+		lastCheckpoint = time.Now()
+	)
+	checkpoint := func() error {
+		if cfg.stateFile == "" || cfg.dryRun {
+			return nil
+		}
+		// Everything up to the checkpoint must really be gone
+		if err := destroyer.wait(); err != nil {
+			return fmt.Errorf("failed to delete: %w", err)
+		}
+		if err := state.save(cfg.stateFile); err != nil {
+			return fmt.Errorf("failed to save state file: %w", err)
+		}
+		unsavedPages = 0
+		lastCheckpoint = time.Now()
+		return nil
+	}
+	// pageDone saves progress after a page once -checkpoint-interval is up
+	pageDone := func() error {
+		unsavedPages++
+		if !cfg.checkpointInterval.due(unsavedPages, time.Since(lastCheckpoint)) {
+			return nil
+		}
+		return checkpoint()
+	}
+	// A run that stops early saves the pages it finished since the last
+	// checkpoint. The state only moves past a page once all of it was
+	// processed, so this never skips anything.
+	defer func() {
+		if unsavedPages == 0 {
+			return
+		}
+		if err := checkpoint(); err != nil {
+			logger.Error("Failed to save progress", zap.Error(err))
+		}
+	}()
+
+	// seen tracks timeline tweets so search results and the archive can skip
+	// them
+	seen := map[int64]bool{}
+
+	// When deleting oldest first, everything is fetched before anything is
+	// processed.
+	var (
+		oldestFirst      = cfg.deleteOrder == orderOldest
+		pendingTweets    []pendingTweet
+		pendingFavorites []twitter.Tweet
+	)
+
+	// prefetchVerified resolves in bulk whether the replies among tweets
+	// that are old enough to delete are to verified users, so
+	// -keep-verified-replies costs a request per page instead of per reply
+	prefetchVerified := func(tweets []twitter.Tweet) error {
+		if verified == nil {
+			return nil
+		}
+		ids, err := replyToUserIDs(cfg.retention, now, tweets)
+		if err != nil {
+			return err
+		}
+		return verified.prefetch(ids)
+	}
+
+	gaps := newGapDetector(cfg.gapWarning, cfg.pageSize)
+	reachedCutoff := false
+	for cfg.targets.tweets && !state.CompletedTweets && !reachedCutoff && tweetFetcher.fetch() {
+		gaps.check(logger, tweetFetcher.tweets)
+		if err := prefetchVerified(tweetFetcher.tweets); err != nil {
+			return err
 		}
 		for _, t := range tweetFetcher.tweets {
-			if err := destroyer.destroyTweet(logger, t); err != nil {
+			if cfg.useSearch || cfg.archive != "" {
+				seen[t.ID] = true
+			}
+			if oldestFirst {
+				pendingTweets = append(pendingTweets, pendingTweet{t, destroyer.destroyTweet})
+				continue
+			}
+			if err := destroyer.process(logger, t, destroyer.destroyTweet); err != nil {
 				return fmt.Errorf("failed to delete: %w", err)
 			}
 		}
+		state.TweetMaxID = tweetFetcher.maxID
+		if err := pageDone(); err != nil {
+			return err
+		}
+		if !cutoff.IsZero() {
+			oldest, err := tweetFetcher.tweets[len(tweetFetcher.tweets)-1].CreatedAtTime()
+			if err != nil {
+				return fmt.Errorf("failed to parse tweet creation time: %w", err)
+			}
+			reachedCutoff = oldest.Before(cutoff)
+		}
+	}
+	if tweetFetcher.err != nil {
+		return fmt.Errorf("failed to fetch: %w", tweetFetcher.err)
+	}
+
+	if cfg.targets.tweets && !state.CompletedTweets && cfg.useSearch {
+		var searchFetcher fetcher = newSearchFetcher(client, account.ScreenName)
+		for searchFetcher.fetch() {
+			tweets, _ := searchFetcher.result()
+			var unseen []twitter.Tweet
+			for _, t := range tweets {
+				if !seen[t.ID] {
+					seen[t.ID] = true
+					unseen = append(unseen, t)
+				}
+			}
+			if err := prefetchVerified(unseen); err != nil {
+				return err
+			}
+			for _, t := range unseen {
+				if oldestFirst {
+					pendingTweets = append(pendingTweets, pendingTweet{t, destroyer.destroyTweet})
+					continue
+				}
+				if err := destroyer.process(logger, t, destroyer.destroyTweet); err != nil {
+					return fmt.Errorf("failed to delete: %w", err)
+				}
+			}
+		}
+		if _, err := searchFetcher.result(); err != nil {
+			return fmt.Errorf("failed to fetch: %w", err)
+		}
+	}
+
+	// The archive reaches tweets beyond the timeline's most recent 3,200
+	if cfg.targets.tweets && !state.CompletedTweets && cfg.archive != "" {
+		// An archive's engagement counts are as of when it was exported, and
+		// archives from some eras have none at all, which would read as zero.
+		// Rules reading them judge the current counts instead. -keep-top-n
+		// looked up what it ranked already.
+		lookupArchived := cfg.verifyBeforeDelete
+		if rules := cfg.retention.engagementRules(); len(rules) > 0 && !lookupArchived {
+			logger.Warn("Looking up archived tweets for current engagement counts, which costs a statuses/lookup request per 100 tweets",
+				zap.Strings("rules", rules))
+			lookupArchived = true
+		}
+		var candidates []archiveTweet
+		for _, at := range archived {
+			if seen[at.tweetID()] {
+				continue
+			}
+			if !cutoff.IsZero() {
+				createdAt, err := at.createdAt()
+				if err != nil {
+					return fmt.Errorf("failed to parse tweet creation time: %w", err)
+				}
+				if createdAt.Before(cutoff) {
+					continue
+				}
+			}
+			seen[at.tweetID()] = true
+			candidates = append(candidates, at)
+		}
+		for len(candidates) > 0 {
+			batch := candidates
+			if len(batch) > maxStatusLookup {
+				batch = batch[:maxStatusLookup]
+			}
+			candidates = candidates[len(batch):]
+
+			// Archives are full of tweets that are long gone, and looking
+			// them up in bulk is cheaper than a 404 from each delete
+			var existing map[int64]twitter.Tweet
+			if lookupArchived {
+				existing, err = lookupExisting(client, batch, !cfg.retention.needsFullUser())
+				if err != nil {
+					return err
+				}
+			}
+			var judged []pendingTweet
+			var tweets []twitter.Tweet
+			for _, at := range batch {
+				// Tweets are judged by the archive, unless they were looked
+				// up for their current state
+				t, err := at.toTweet()
+				if err != nil {
+					return err
+				}
+				destroy := destroyer.destroyArchived(at)
+				if existing != nil {
+					current, ok := existing[t.ID]
+					if !ok {
+						logger.Debug("Already gone", zap.Int64("id", t.ID), zap.String("kind", failureTweet))
+						unlock := destroyer.lock()
+						destroyer.stats.alreadyGone++
+						unlock()
+						continue
+					}
+					t, destroy = current, destroyer.destroyTweet
+				}
+				judged = append(judged, pendingTweet{t, destroy})
+				tweets = append(tweets, t)
+			}
+			if err := prefetchVerified(tweets); err != nil {
+				return err
+			}
+			for _, p := range judged {
+				if oldestFirst {
+					pendingTweets = append(pendingTweets, p)
+					continue
+				}
+				if err := destroyer.process(logger, p.Tweet, p.destroy); err != nil {
+					return fmt.Errorf("failed to delete: %w", err)
+				}
+			}
+		}
 	}
 
-	for favoriteFetcher.fetch() {
-		if favoriteFetcher.err != nil {
-			return fmt.Errorf("failed to fetch: %w", favoriteFetcher.err)
+	// Tweet IDs increase over time, so sorting by ID sorts by age
+	sort.Slice(pendingTweets, func(i, j int) bool {
+		return pendingTweets[i].ID < pendingTweets[j].ID
+	})
+	for _, p := range pendingTweets {
+		if err := destroyer.process(logger, p.Tweet, p.destroy); err != nil {
+			return fmt.Errorf("failed to delete: %w", err)
+		}
+	}
+	state.CompletedTweets = true
+	if err := checkpoint(); err != nil {
+		return err
+	}
+
+	for cfg.targets.favorites && !state.CompletedFavorites && favoriteFetcher.fetch() {
+		if err := prefetchVerified(favoriteFetcher.tweets); err != nil {
+			return err
 		}
 		for _, t := range favoriteFetcher.tweets {
-			if err := destroyer.destroyFavorite(logger, t); err != nil {
+			if oldestFirst {
+				pendingFavorites = append(pendingFavorites, t)
+				continue
+			}
+			if err := destroyer.process(logger, t, destroyer.destroyFavorite); err != nil {
 				return fmt.Errorf("failed to delete: %w", err)
 			}
 		}
+		state.FavoriteMaxID = favoriteFetcher.maxID
+		if err := pageDone(); err != nil {
+			return err
+		}
+	}
+	if favoriteFetcher.err != nil {
+		return fmt.Errorf("failed to fetch: %w", favoriteFetcher.err)
+	}
+
+	// Favorites are listed most recently favorited first
+	for i := len(pendingFavorites) - 1; i >= 0; i-- {
+		if err := destroyer.process(logger, pendingFavorites[i], destroyer.destroyFavorite); err != nil {
+			return fmt.Errorf("failed to delete: %w", err)
+		}
+	}
+	state.CompletedFavorites = true
+	if err := checkpoint(); err != nil {
+		return err
+	}
+
+	if cfg.targets.dms {
+		dmFetcher := newDMFetcher(client)
+		for dmFetcher.fetch() {
+			for _, dm := range dmFetcher.events {
+				id, err := strconv.ParseInt(dm.ID, 10, 64)
+				if err != nil {
+					return fmt.Errorf("failed to parse direct message ID %q: %w", dm.ID, err)
+				}
+				dm := dm
+				if err := destroyer.guard(logger, id, func() error {
+					return destroyer.destroyDM(logger, id, dm)
+				}); err != nil {
+					return fmt.Errorf("failed to delete: %w", err)
+				}
+			}
+		}
+		if dmFetcher.err != nil {
+			return fmt.Errorf("failed to fetch: %w", dmFetcher.err)
+		}
+	}
+
+	if err := destroyer.wait(); err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+
+	if destroyer.sample != nil {
+		for _, dec := range destroyer.sample.items {
+			logger.Info(dec.decision,
+				zap.Int64("id", dec.id),
+				zap.String("text", cfg.logText(dec.text)))
+		}
+	}
+
+	if destroyer.plan != nil {
+		if err := destroyer.plan.writeFile(cfg.planHTML, now); err != nil {
+			return fmt.Errorf("failed to write plan report: %w", err)
+		}
 	}
 
+	if cfg.validateKeepIDs {
+		if unmatched := cfg.retention.unmatchedIDs(); len(unmatched) > 0 {
+			logger.Warn("Keep IDs never matched a tweet or favorite; they may be deleted or mistyped",
+				zap.Int64s("ids", unmatched))
+		}
+	}
+	// Everything was scanned, so the next run starts from the top
+	if cfg.stateFile != "" && !cfg.dryRun {
+		if err := (runState{LastCompletedAt: now}).save(cfg.stateFile); err != nil {
+			return fmt.Errorf("failed to save state file: %w", err)
+		}
+	}
+	if n := len(multierr.Errors(collected)); n > 0 {
+		return fmt.Errorf("%d tweets failed: %w", n, collected)
+	}
 	return nil
 }
 
+// verifyCredentials checks the credentials and returns the account they belong
+// to.
+func verifyCredentials(client *twitter.Client) (*twitter.User, error) {
+	var account *twitter.User
+	_, err := call(func() (resp *http.Response, err error) {
+		account, resp, err = client.Accounts.VerifyCredentials(nil)
+		return resp, err
+	})
+	if err != nil {
+		if statusErr := accountStatusError(err); statusErr != nil {
+			return nil, statusErr
+		}
+		if errors.Is(err, errAuth) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to verify credentials: %w", err)
+	}
+	return account, nil
+}
+
+// newHTTPClient returns an http.Client that signs requests with the configured
+// OAuth credentials.
+func newHTTPClient(cfg config) (*http.Client, error) {
+	var transport http.RoundTripper
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.apiBaseURL != "" {
+		base, err := url.Parse(cfg.apiBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		transport = baseURLTransport{base: base, next: transport}
+	}
+	if cfg.userAgent != "" {
+		transport = userAgentTransport{userAgent: cfg.userAgent, next: transport}
+	}
+	transport = newRateLimitTransport(transport)
+
+	// oauth1 wraps the transport of the client found in the context with its
+	// signing transport.
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, &http.Client{Transport: transport})
+	return &http.Client{
+		Transport: newRefreshTransport(ctx, newTokenSource(cfg)),
+		Timeout:   cfg.httpTimeout,
+	}, nil
+}
+
+// newTransport returns the base transport for requests, using the configured
+// proxy.
+func newTransport(cfg config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.proxy != "" {
+		proxyURL, err := url.Parse(cfg.proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+// exportTweets writes every tweet on the account's timeline to the backup
+// file. Retention rules aren't applied and nothing is deleted.
+func exportTweets(logger *zap.Logger, client *twitter.Client, account *twitter.User, cfg config) error {
+	backup, err := openBackupFile(cfg.backupFile)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer backup.Close()
+
+	var exported int
+	tweetFetcher := newTweetFetcher(client, account.ScreenName, cfg.pageSize, false)
+	for tweetFetcher.fetch() {
+		for _, t := range tweetFetcher.tweets {
+			if err := backup.add(t); err != nil {
+				return fmt.Errorf("failed to back up tweet: %w", err)
+			}
+			exported++
+		}
+	}
+	if tweetFetcher.err != nil {
+		return fmt.Errorf("failed to fetch: %w", tweetFetcher.err)
+	}
+	logger.Info("Exported timeline", zap.Int("tweets", exported))
+	return backup.Close()
+}
+
+// countTombstoned counts how many tweets and favorites would be deleted
+// under the retention policy, including thinning. It sweeps the same tweets a
+// run does, from the timeline, search and the archive, as a dry run writing
+// nothing, so it costs as many requests again. Messages aren't counted.
+func countTombstoned(ctx context.Context, cfg config, logger *zap.Logger, client *twitter.Client, account *twitter.User, archived []archiveTweet, verified *verifiedCache, now time.Time) (tweets, favorites int, err error) {
+	logger.Info("Counting what the policy would delete")
+	cfg.dryRun = true
+	cfg.targets.dms = false
+	cfg.deletedIDsFile = ""
+	cfg.backupFile = ""
+	cfg.failedFile = ""
+	cfg.planHTML = ""
+	cfg.reportKept = ""
+	cfg.sample = 0
+	cfg.reportByYear = false
+	cfg.validateKeepIDs = false
+	cfg.inFlight = 1
+	st := &stats{}
+	// Every decision is logged again by the run itself
+	if err := sweep(ctx, cfg, zap.NewNop(), client, account, archived, verified, nil, now, st); err != nil {
+		return 0, 0, err
+	}
+	return st.tweetsDeleted + st.unretweeted, st.favoritesDeleted, nil
+}
+
+// fetcher is an iterator over pages of tweets
+type fetcher interface {
+	// fetch gets the next page. It returns false when there are no more
+	// pages or an error occurred.
+	fetch() bool
+	// result returns the current page of tweets and any error from fetch
+	result() ([]twitter.Tweet, error)
+}
+
 // tweetFetcher steps across all tweets in a username's timeline
 type tweetFetcher struct {
 	client   *twitter.Client
 	username string
+	pageSize int
+	trimUser bool
 	maxID    int64
 
 	tweets []twitter.Tweet
 	err    error
 }
 
-// newTweetFetcher returns a new fetcher
-func newTweetFetcher(client *twitter.Client, username string) *tweetFetcher {
+// newTweetFetcher returns a new fetcher. When trimUser is set, each tweet's
+// user object is trimmed down to the user's ID.
+func newTweetFetcher(client *twitter.Client, username string, pageSize int, trimUser bool) *tweetFetcher {
 	return &tweetFetcher{
 		client:   client,
 		username: username,
+		pageSize: pageSize,
+		trimUser: trimUser,
 	}
 }
 
@@ -166,28 +1607,22 @@ func newTweetFetcher(client *twitter.Client, username string) *tweetFetcher {
 // occur will be reflected in the "err" field.
 func (f *tweetFetcher) fetch() bool {
 	var (
-		resp   *http.Response
-		err    error
 		on     = true
 		params = &twitter.UserTimelineParams{
 			ScreenName:      f.username,
-			Count:           200,
+			Count:           f.pageSize,
 			MaxID:           f.maxID,
 			IncludeRetweets: &on,
-			TrimUser:        &on,
+			TrimUser:        &f.trimUser,
 		}
 	)
-	f.tweets, resp, err = f.client.Timelines.UserTimeline(params)
+	_, err := call(func() (resp *http.Response, err error) {
+		f.tweets, resp, err = f.client.Timelines.UserTimeline(params)
+		return resp, err
+	})
 	if err != nil {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			if err := backOff(resp.Header); err != nil {
-				f.err = fmt.Errorf("failed to back off: %w", err)
-				return false
-			}
-		} else {
-			f.err = fmt.Errorf("failed to fetch tweets: %w", err)
-			return false
-		}
+		f.err = fmt.Errorf("failed to fetch tweets: %w", err)
+		return false
 	}
 	if len(f.tweets) > 0 {
 		f.maxID = f.tweets[len(f.tweets)-1].ID - 1
@@ -196,10 +1631,16 @@ func (f *tweetFetcher) fetch() bool {
 	return false
 }
 
+// result returns the most recently fetched tweets
+func (f *tweetFetcher) result() ([]twitter.Tweet, error) {
+	return f.tweets, f.err
+}
+
 // favoriteFetcher fetches favorited tweets
 type favoriteFetcher struct {
 	client    *twitter.Client
 	accountID int64
+	pageSize  int
 	maxID     int64
 
 	tweets []twitter.Tweet
@@ -207,10 +1648,11 @@ type favoriteFetcher struct {
 }
 
 // newFavoriteFetcher returns a new favorite fetcher
-func newFavoriteFetcher(client *twitter.Client, accountID int64) *favoriteFetcher {
+func newFavoriteFetcher(client *twitter.Client, accountID int64, pageSize int) *favoriteFetcher {
 	return &favoriteFetcher{
 		client:    client,
 		accountID: accountID,
+		pageSize:  pageSize,
 	}
 }
 
@@ -221,26 +1663,86 @@ func newFavoriteFetcher(client *twitter.Client, accountID int64) *favoriteFetche
 // The resulting tweets are stored in the "tweets" struct field. Any errors that
 // occur will be reflected in the "err" field.
 func (f *favoriteFetcher) fetch() bool {
-	var (
-		resp   *http.Response
-		err    error
-		params = &twitter.FavoriteListParams{
-			UserID: f.accountID,
-			Count:  200,
-			MaxID:  f.maxID,
-		}
-	)
-	f.tweets, resp, err = f.client.Favorites.List(params)
+	// Entities are included by default, but keep rules such as
+	// -keep-hashtags depend on them
+	on := true
+	params := &twitter.FavoriteListParams{
+		UserID:          f.accountID,
+		Count:           f.pageSize,
+		MaxID:           f.maxID,
+		IncludeEntities: &on,
+	}
+	_, err := call(func() (resp *http.Response, err error) {
+		f.tweets, resp, err = f.client.Favorites.List(params)
+		return resp, err
+	})
 	if err != nil {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			if err := backOff(resp.Header); err != nil {
-				f.err = fmt.Errorf("failed to back off: %w", err)
-				return false
-			}
+		if isDeprecated(err) {
+			f.err = errFavoritesDeprecated
 		} else {
 			f.err = fmt.Errorf("failed to fetch tweets: %w", err)
-			return false
 		}
+		return false
+	}
+	if len(f.tweets) > 0 {
+		f.maxID = f.tweets[len(f.tweets)-1].ID - 1
+		return true
+	}
+	return false
+}
+
+// result returns the most recently fetched favorites
+func (f *favoriteFetcher) result() ([]twitter.Tweet, error) {
+	return f.tweets, f.err
+}
+
+// searchFetcher steps across a username's tweets using the search API. Search
+// isn't bound by the timeline's ~3,200 tweet limit, but only covers what
+// Twitter's search index has.
+type searchFetcher struct {
+	client   *twitter.Client
+	username string
+	maxID    int64
+
+	tweets []twitter.Tweet
+	err    error
+}
+
+// newSearchFetcher returns a new search fetcher
+func newSearchFetcher(client *twitter.Client, username string) *searchFetcher {
+	return &searchFetcher{
+		client:   client,
+		username: username,
+	}
+}
+
+// fetch gets a page of search results. It should be called continuously as an
+// iterator. A return value of "true" means there are potentially more tweets
+// to be fetched. A value of "false" means there are no more tweets to be
+// fetched.
+func (f *searchFetcher) fetch() bool {
+	var (
+		search *twitter.Search
+		on     = true
+		params = &twitter.SearchTweetParams{
+			Query:           "from:" + f.username,
+			ResultType:      "recent",
+			Count:           100,
+			MaxID:           f.maxID,
+			IncludeEntities: &on,
+		}
+	)
+	f.tweets = nil
+	_, err := call(func() (resp *http.Response, err error) {
+		search, resp, err = f.client.Search.Tweets(params)
+		return resp, err
+	})
+	if err != nil {
+		f.err = fmt.Errorf("failed to search tweets: %w", err)
+		return false
+	}
+	if search != nil {
+		f.tweets = search.Statuses
 	}
 	if len(f.tweets) > 0 {
 		f.maxID = f.tweets[len(f.tweets)-1].ID - 1
@@ -249,120 +1751,553 @@ func (f *favoriteFetcher) fetch() bool {
 	return false
 }
 
+// result returns the most recently fetched search results
+func (f *searchFetcher) result() ([]twitter.Tweet, error) {
+	return f.tweets, f.err
+}
+
+// pendingTweet is a tweet held back to be processed oldest first, with the
+// function to destroy it with
+type pendingTweet struct {
+	twitter.Tweet
+	destroy func(*zap.Logger, twitter.Tweet) error
+}
+
 // destroyer deletes tweets and favorites based on retention rules
 type destroyer struct {
 	client    *twitter.Client
 	now       time.Time
 	retention retention
+	dryRun    bool
+	stats     *stats
+
+	// deletedIDs records successfully deleted IDs when set
+	deletedIDs *idFile
+	// backup records tweets before they're deleted when set
+	backup *backupFile
+	// failed records IDs that couldn't be deleted when set
+	failed *failedFile
+	// collected, when set, gathers errors from individual tweets so the run
+	// can carry on and report them all at the end
+	collected *error
+	// thinning, when set, spares one tweet per day from deletion
+	thinning *thinning
+	// sample, when set, collects a random sample of decisions instead of
+	// logging every one
+	sample *reservoir
+	// strategy removes tweets that aren't retweets
+	strategy removalStrategy
+	// pool, when set, runs deletions concurrently
+	pool *deletePool
+	// plan, when set, collects what a dry run would delete
+	plan *planReport
+	// kept, when set, records what a dry run keeps and why
+	kept *keptReport
+	// controlFile, when set, pauses deletions while it exists
+	controlFile string
+	// window, when set, stops the run once deletions fall outside it
+	window *activeWindow
+	// byYear, when set, tallies tweets by the year they were posted in this
+	// location
+	byYear *time.Location
+	// stop, when set, is closed once the run has been asked to stop, and no
+	// tweets are processed after that
+	stop <-chan struct{}
+	// destroyed holds what's been destroyed this run
+	destroyed map[destroyedKey]bool
+	// decidedTweets holds the tweets decided on this run, deleted or kept, so
+	// the favorites pass leaves alone those that are also favorites
+	decidedTweets map[int64]bool
 }
 
-// newDestroyer returns a new destroyer
-func newDestroyer(client *twitter.Client, r retention) destroyer {
+// newDestroyer returns a new destroyer. When dryRun is set, nothing is deleted.
+func newDestroyer(client *twitter.Client, r retention, now time.Time, dryRun bool) destroyer {
 	return destroyer{
-		client:    client,
-		now:       time.Now(),
-		retention: r,
+		client:        client,
+		now:           now,
+		retention:     r,
+		dryRun:        dryRun,
+		stats:         &stats{},
+		strategy:      deleteStrategy{},
+		destroyed:     map[destroyedKey]bool{},
+		decidedTweets: map[int64]bool{},
 	}
 }
 
-// destroyTweet deletes a tweet
+// destroyTweet deletes a tweet from the API
 func (d destroyer) destroyTweet(logger *zap.Logger, t twitter.Tweet) error {
+	return d.destroyRecord(logger, t, apiTweet{t})
+}
+
+// destroyArchived returns a destroy function for process that deletes an
+// archived tweet, judging it by the archive's record of it
+func (d destroyer) destroyArchived(at archiveTweet) func(*zap.Logger, twitter.Tweet) error {
+	return func(logger *zap.Logger, t twitter.Tweet) error {
+		return d.destroyRecord(logger, t, at)
+	}
+}
+
+// destroyRecord deletes a tweet if the retention rules evict rec, what its
+// source knows about it. t is the tweet as the API has it, for deleting it and
+// for logs, backups and reports.
+func (d destroyer) destroyRecord(logger *zap.Logger, t twitter.Tweet, rec tweetRecord) error {
 	logger = logger.With(
 		zap.Int64("id", t.ID))
+	if d.wasDestroyed(failureTweet, t.ID) {
+		logger.Debug("Already deleted this run")
+		return nil
+	}
 
-	evict, err := d.retention.isTombstoned(logger, t, d.now)
+	evict, reason, err := d.retention.isTombstoned(logger, rec, d.now)
 	if err != nil {
 		return err
 	}
+	d.decidedTweets[t.ID] = true
+	if evict && d.thinning != nil {
+		survivor, err := d.thinning.isSurvivor(t)
+		if err != nil {
+			return err
+		}
+		evict = !survivor
+		if survivor {
+			reason = "-thin-keep-first-per-day"
+		}
+	}
 	if !evict {
-		logger.Info("Keeping Tweet")
-		return nil
+		d.decide(logger.With(zap.String("reason", reason)), "Keeping Tweet", t.ID, t.Text)
+		// In-flight deletions update the same stats
+		defer d.lock()()
+		d.stats.tweetsKept++
+		if err := d.reportKept(failureTweet, t, reason); err != nil {
+			return err
+		}
+		return d.tallyYear(t, false)
 	}
 
-	logger.Info("Deleting Tweet")
-	_, resp, err := d.client.Statuses.Destroy(t.ID, nil)
-	if err != nil {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			if err := backOff(resp.Header); err != nil {
-				return fmt.Errorf("failed to back off: %w", err)
+	// Destroying a retweet un-retweets it; the original tweet is untouched.
+	retweet := t.RetweetedStatus != nil
+
+	if d.dryRun {
+		if d.plan != nil {
+			kind := "Tweet"
+			if retweet {
+				kind = "Retweet"
 			}
+			if err := d.plan.add(kind, t); err != nil {
+				return err
+			}
+		}
+		defer d.lock()()
+		if retweet {
+			d.decide(logger, "Would un-retweet", t.ID, t.Text)
+			d.stats.unretweeted++
+		} else {
+			d.decide(logger, "Would delete Tweet", t.ID, t.Text)
+			d.stats.tweetsDeleted++
+		}
+		return d.tallyYear(t, true)
+	}
+
+	if d.backup != nil {
+		if err := d.backup.add(t); err != nil {
+			return fmt.Errorf("failed to back up tweet: %w", err)
+		}
+	}
+	if retweet {
+		logger.Info("Un-retweeting")
+	} else {
+		logger.Info("Deleting Tweet")
+	}
+	return d.async(logger, t.ID, func() error {
+		var (
+			deleted bool
+			err     error
+		)
+		if retweet {
+			deleted, err = d.deleteStatus(logger, t.ID)
+		} else {
+			deleted, err = d.strategy.remove(logger, d, t.ID)
+		}
+		if err != nil || !deleted {
+			return err
+		}
+		defer d.lock()()
+		if retweet {
+			d.stats.unretweeted++
 		} else {
+			d.stats.tweetsDeleted++
+		}
+		if err := d.tallyYear(t, true); err != nil {
 			return err
 		}
+		return d.recordDeleted(failureTweet, t.ID)
+	})
+}
+
+// tallyYear counts a deleted or kept tweet under the year it was posted, when
+// tallying by year. The caller must hold d.lock.
+func (d destroyer) tallyYear(t twitter.Tweet, deleted bool) error {
+	if d.byYear == nil {
+		return nil
+	}
+	createdAt, err := t.CreatedAtTime()
+	if err != nil {
+		return err
 	}
+	d.stats.tallyYear(createdAt.In(d.byYear).Year(), deleted)
 	return nil
 }
 
-// destroyFavorite deletes a favorited tweet
+// destroyFavorite deletes a favorited tweet. A favorite of one of the
+// account's own tweets that was already decided on as a tweet is skipped: the
+// tweet's decision wins, and deleting the tweet removes the favorite with it.
 func (d destroyer) destroyFavorite(logger *zap.Logger, t twitter.Tweet) error {
 	logger = logger.With(
 		zap.Int64("id", t.ID))
+	if d.wasDestroyed(failureFavorite, t.ID) {
+		logger.Debug("Already deleted this run")
+		return nil
+	}
+	if d.decidedTweets[t.ID] {
+		logger.Debug("Already decided on as a tweet")
+		return nil
+	}
 
-	evict, err := d.retention.isTombstoned(logger, t, d.now)
+	evict, reason, err := d.retention.isTombstoned(logger, apiTweet{t}, d.now)
 	if err != nil {
 		return err
 	}
 	if !evict {
-		logger.Info("Keeping Favorite")
-		return nil
+		d.decide(logger.With(zap.String("reason", reason)), "Keeping Favorite", t.ID, t.Text)
+		defer d.lock()()
+		d.stats.favoritesKept++
+		return d.reportKept(failureFavorite, t, reason)
 	}
 
-	logger.Info("Deleting Favorite")
-	_, resp, err := d.client.Favorites.Destroy(&twitter.FavoriteDestroyParams{
-		ID: t.ID,
-	})
-	if err != nil {
-		if resp.StatusCode == http.StatusTooManyRequests {
-			if err := backOff(resp.Header); err != nil {
-				return fmt.Errorf("failed to back off: %w", err)
+	if d.dryRun {
+		if d.plan != nil {
+			if err := d.plan.add("Favorite", t); err != nil {
+				return err
 			}
-		} else {
+		}
+		d.decide(logger, "Would unfavorite", t.ID, t.Text)
+		defer d.lock()()
+		d.stats.favoritesDeleted++
+		return nil
+	}
+
+	logger.Info("Unfavoriting")
+	return d.async(logger, t.ID, func() error {
+		deleted, err := d.deleteFavorite(logger, t.ID)
+		if err != nil || !deleted {
 			return err
 		}
+		defer d.lock()()
+		d.stats.favoritesDeleted++
+		return d.recordDeleted(failureFavorite, t.ID)
+	})
+}
+
+// reportKept records a kept tweet or favorite in the kept report, if there is
+// one
+func (d destroyer) reportKept(kind string, t twitter.Tweet, reason string) error {
+	if d.kept == nil {
+		return nil
+	}
+	if err := d.kept.add(kind, t, reason); err != nil {
+		return fmt.Errorf("failed to write kept report: %w", err)
 	}
 	return nil
 }
 
-// backOff extracts rate-limit back-off information from the response and sleeps
-// that number of seconds.
-func backOff(header http.Header) error {
-	reset, err := strconv.Atoi(header.Get("X-Rate-Limit-Reset"))
-	if err != nil {
+// decide logs the decision made about a tweet or message. When sampling, the
+// decision is only logged at debug level and offered to the sample instead.
+func (d destroyer) decide(logger *zap.Logger, decision string, id int64, text string) {
+	if d.sample == nil {
+		logger.Info(decision)
+		return
+	}
+	logger.Debug(decision)
+	d.sample.add(sampledDecision{
+		id:       id,
+		decision: decision,
+		text:     text,
+	})
+}
+
+// process runs destroy for a single tweet, turning a panic into an error so one
+// bad tweet can't take down a long run. When errors are being collected, they
+// are logged and counted instead of returned, except for authentication
+// errors, which would fail for every tweet.
+func (d destroyer) process(logger *zap.Logger, t twitter.Tweet, destroy func(*zap.Logger, twitter.Tweet) error) error {
+	return d.guard(logger, t.ID, func() error {
+		return destroy(logger, t)
+	})
+}
+
+// guard runs destroy for the tweet or message with the given ID, applying the
+// panic recovery and error handling described on process.
+func (d destroyer) guard(logger *zap.Logger, id int64, destroy func() error) error {
+	if d.stopped() {
+		return stoppedError{errInterrupted}
+	}
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return destroy()
+	}()
+	if err == nil {
+		return nil
+	}
+	return d.countError(logger, id, err)
+}
+
+// countError counts an error from processing the tweet or message with the
+// given ID, returning it unless errors are being collected.
+func (d destroyer) countError(logger *zap.Logger, id int64, err error) error {
+	if errors.As(err, &stoppedError{}) {
+		return err
+	}
+	defer d.lock()()
+	d.stats.errors++
+	if d.collected == nil || errors.Is(err, errAuth) {
+		return err
+	}
+	logger.Error("Failed to process tweet",
+		zap.Int64("id", id),
+		zap.Error(err))
+	if d.collected != nil {
+		*d.collected = multierr.Append(*d.collected, err)
+	}
+	return nil
+}
+
+// retry re-attempts a deletion that previously failed, skipping the retention
+// rules since they were already applied when it failed.
+func (d destroyer) retry(logger *zap.Logger, f failure) error {
+	logger = logger.With(
+		zap.Int64("id", f.id),
+		zap.String("kind", f.kind))
+
+	if d.dryRun {
+		logger.Info("Would retry")
+		return nil
+	}
+
+	logger.Info("Retrying")
+	var (
+		deleted bool
+		err     error
+	)
+	switch f.kind {
+	case failureTweet:
+		deleted, err = d.deleteStatus(logger, f.id)
+		if deleted {
+			d.stats.tweetsDeleted++
+		}
+	case failureFavorite:
+		deleted, err = d.deleteFavorite(logger, f.id)
+		if deleted {
+			d.stats.favoritesDeleted++
+		}
+	case failureDM:
+		deleted, err = d.deleteDM(logger, f.id)
+		if deleted {
+			d.stats.dmsDeleted++
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", f.kind)
+	}
+	if err != nil || !deleted {
 		return err
 	}
-	time.Sleep(time.Duration(reset) * time.Second)
+	return d.recordDeleted(f.kind, f.id)
+}
+
+// deleteStatus deletes (or un-retweets) a tweet by ID. It reports whether the
+// tweet was deleted; tweets that are already gone aren't.
+func (d destroyer) deleteStatus(logger *zap.Logger, id int64) (bool, error) {
+	_, err := call(func() (*http.Response, error) {
+		_, resp, err := d.client.Statuses.Destroy(id, nil)
+		return resp, err
+	})
+	return d.deleted(logger, failureTweet, id, err)
+}
+
+// deleteFavorite un-favorites a tweet by ID. It reports whether the favorite
+// was removed; favorites that are already gone aren't.
+func (d destroyer) deleteFavorite(logger *zap.Logger, id int64) (bool, error) {
+	_, err := call(func() (*http.Response, error) {
+		_, resp, err := d.client.Favorites.Destroy(&twitter.FavoriteDestroyParams{
+			ID: id,
+		})
+		return resp, err
+	})
+	return d.deleted(logger, failureFavorite, id, err)
+}
+
+// deleted interprets the outcome of a delete request. Deletions that fail for
+// any reason other than bad credentials are recorded in the failed file.
+func (d destroyer) deleted(logger *zap.Logger, kind string, id int64, err error) (bool, error) {
+	defer d.lock()()
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, errNotFound):
+		logger.Debug("Already gone", zap.String("kind", kind))
+		d.stats.alreadyGone++
+		return false, nil
+	case errors.Is(err, errAuth):
+		return false, err
+	}
+	if ferr := d.recordFailed(kind, id, err); ferr != nil {
+		return false, fmt.Errorf("%v (%w)", err, ferr)
+	}
+	return false, err
+}
+
+// recordDeleted marks an ID of the given kind as destroyed this run and
+// appends it to the deleted IDs file, if there is one.
+func (d destroyer) recordDeleted(kind string, id int64) error {
+	d.destroyed[destroyedKey{kind, id}] = true
+	if d.deletedIDs == nil {
+		return nil
+	}
+	if err := d.deletedIDs.add(id); err != nil {
+		return fmt.Errorf("failed to record deleted ID: %w", err)
+	}
+	return nil
+}
+
+// destroyedKey identifies a destroyed tweet, favorite or message. A tweet and
+// its favorite share an ID.
+type destroyedKey struct {
+	kind string
+	id   int64
+}
+
+// wasDestroyed reports whether the ID of the given kind was already destroyed
+// this run. Twitter sometimes keeps serving deleted tweets for a while, and
+// they shouldn't be deleted again.
+func (d destroyer) wasDestroyed(kind string, id int64) bool {
+	defer d.lock()()
+	return d.destroyed[destroyedKey{kind, id}]
+}
+
+// recordFailed appends a failed deletion to the failed file, if there is one
+func (d destroyer) recordFailed(kind string, id int64, cause error) error {
+	if d.failed == nil {
+		return nil
+	}
+	if err := d.failed.add(failure{kind: kind, id: id, err: cause.Error()}); err != nil {
+		return fmt.Errorf("failed to record failed deletion: %w", err)
+	}
 	return nil
 }
 
-// retention is the retention policy
-type retention struct {
-	ids      []int64
-	keywords []string
-	maxAge   time.Duration
+// thinning spares the first tweet seen each day that would otherwise be
+// deleted, thinning out old history rather than removing it all. Tweets are
+// seen newest first unless -delete-order=oldest, so by default the survivor
+// is the latest such tweet of its day. Tweets kept by retention rules don't
+// count as a day's survivor; thinning only applies to tweets the rules would
+// delete.
+type thinning struct {
+	loc  *time.Location
+	days map[string]bool
+}
+
+// newThinning returns a new thinning whose days are in loc
+func newThinning(loc *time.Location) *thinning {
+	return &thinning{
+		loc:  loc,
+		days: map[string]bool{},
+	}
 }
 
-// isTombstoned determines whether or not a tweet should be deleted
-func (r retention) isTombstoned(logger *zap.Logger, t twitter.Tweet, now time.Time) (bool, error) {
+// isSurvivor reports whether t is the first tweet seen on its day, marking the
+// day as having a survivor if so.
+func (th *thinning) isSurvivor(t twitter.Tweet) (bool, error) {
 	createdAt, err := t.CreatedAtTime()
 	if err != nil {
 		return false, err
 	}
-	age := now.Sub(createdAt)
-
-	if age < r.maxAge {
+	day := createdAt.In(th.loc).Format("2006-01-02")
+	if th.days[day] {
 		return false, nil
 	}
-	for _, id := range r.ids {
-		if id == t.ID {
-			return false, nil
-		}
+	th.days[day] = true
+	return true, nil
+}
+
+// stats tallies what the destroyer did over a run. In a dry run the deletion
+// counts are what would have been deleted.
+type stats struct {
+	tweetsDeleted    int
+	unretweeted      int
+	tweetsKept       int
+	favoritesDeleted int
+	favoritesKept    int
+	dmsDeleted       int
+	dmsKept          int
+	alreadyGone      int
+	errors           int
+	// years tallies tweets by the year they were posted, with -report-by-year
+	years map[int]*yearCount
+}
+
+// yearCount is how many tweets posted in a year were deleted and kept.
+// Deleted includes un-retweets.
+type yearCount struct {
+	Year    int `json:"year"`
+	Deleted int `json:"deleted"`
+	Kept    int `json:"kept"`
+}
+
+// tallyYear counts a deleted or kept tweet posted in year
+func (s *stats) tallyYear(year int, deleted bool) {
+	if s.years == nil {
+		s.years = map[int]*yearCount{}
 	}
-	for _, keyword := range r.keywords {
-		if strings.Contains(t.Text, keyword) {
-			return false, nil
-		}
+	c, ok := s.years[year]
+	if !ok {
+		c = &yearCount{Year: year}
+		s.years[year] = c
+	}
+	if deleted {
+		c.Deleted++
+	} else {
+		c.Kept++
+	}
+}
+
+// byYear returns the yearly tallies, oldest first
+func (s *stats) byYear() []yearCount {
+	counts := make([]yearCount, 0, len(s.years))
+	for _, c := range s.years {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Year < counts[j].Year
+	})
+	return counts
+}
+
+// fields returns the stats as log fields
+func (s *stats) fields() []zap.Field {
+	return []zap.Field{
+		zap.Int("tweets_deleted", s.tweetsDeleted),
+		zap.Int("unretweeted", s.unretweeted),
+		zap.Int("tweets_kept", s.tweetsKept),
+		zap.Int("favorites_deleted", s.favoritesDeleted),
+		zap.Int("favorites_kept", s.favoritesKept),
+		zap.Int("dms_deleted", s.dmsDeleted),
+		zap.Int("dms_kept", s.dmsKept),
+		zap.Int("already_gone", s.alreadyGone),
+		zap.Int("errors", s.errors),
 	}
-	return true, nil
 }
 
 func parseKeepIDs(v string) ([]int64, error) {
@@ -371,7 +2306,7 @@ func parseKeepIDs(v string) ([]int64, error) {
 	}
 	var int64s []int64
 	for _, s := range strings.Split(v, ",") {
-		p, err := strconv.ParseInt(s, 10, 64)
+		p, err := parseTweetID(strings.TrimSpace(s))
 		if err != nil {
 			return nil, err
 		}
@@ -380,6 +2315,81 @@ func parseKeepIDs(v string) ([]int64, error) {
 	return int64s, nil
 }
 
+// parseTweetID parses either a bare tweet ID or a status URL like
+// https://twitter.com/user/status/123456.
+func parseTweetID(v string) (int64, error) {
+	if !strings.Contains(v, "/") {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid tweet ID %q", v)
+		}
+		return id, nil
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tweet URL %q: %w", v, err)
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return 0, fmt.Errorf("invalid tweet URL %q: expected a /status/<id> path", v)
+	}
+	switch segments[len(segments)-2] {
+	case "status", "statuses":
+	default:
+		return 0, fmt.Errorf("invalid tweet URL %q: expected a /status/<id> path", v)
+	}
+	id, err := strconv.ParseInt(segments[len(segments)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tweet URL %q: %q is not a tweet ID", v, segments[len(segments)-1])
+	}
+	return id, nil
+}
+
+// parseListIDs parses a comma-separated list of list IDs
+func parseListIDs(v string) ([]int64, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	var ids []int64
+	for _, s := range strings.Split(v, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid list ID %q", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseIDRanges parses a comma-separated list of ID ranges like "100-200"
+func parseIDRanges(v string) ([]idRange, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	var ranges []idRange
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		bounds := strings.SplitN(s, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid ID range %q: expected min-max", s)
+		}
+		min, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		if err != nil || min < 0 {
+			return nil, fmt.Errorf("invalid ID range %q: %q is not a tweet ID", s, bounds[0])
+		}
+		max, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid ID range %q: %q is not a tweet ID", s, bounds[1])
+		}
+		if min > max {
+			return nil, fmt.Errorf("invalid ID range %q: min is greater than max", s)
+		}
+		ranges = append(ranges, idRange{min: min, max: max})
+	}
+	return ranges, nil
+}
+
 func parseKeepKeywords(v string) []string {
 	if len(v) == 0 {
 		return nil
@@ -387,11 +2397,122 @@ func parseKeepKeywords(v string) []string {
 	return strings.Split(v, ",")
 }
 
-func newLogger(logLevel string) (*zap.Logger, error) {
+func parseKeepLangs(v string) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	var langs []string
+	for _, s := range strings.Split(v, ",") {
+		langs = append(langs, strings.TrimSpace(s))
+	}
+	return langs
+}
+
+// targets are the kinds of content a run prunes
+type targets struct {
+	tweets, favorites, dms bool
+}
+
+// parseTargets parses a comma-separated list of targets
+func parseTargets(v string) (targets, error) {
+	var t targets
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "tweets":
+			t.tweets = true
+		case "favorites":
+			t.favorites = true
+		case "dms":
+			t.dms = true
+		case "":
+		default:
+			return targets{}, fmt.Errorf("unknown target %q: expected tweets, favorites or dms", name)
+		}
+	}
+	return t, nil
+}
+
+// parseUsers splits a comma-separated list of users into screen names, with
+// any leading "@" dropped, and numeric user IDs.
+func parseUsers(v string) (names []string, ids []int64) {
+	for _, name := range parseScreenNames(v) {
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			ids = append(ids, id)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, ids
+}
+
+// parseScreenNames splits a comma-separated list of screen names, dropping any
+// leading "@".
+func parseScreenNames(v string) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	var names []string
+	for _, s := range strings.Split(v, ",") {
+		names = append(names, strings.TrimPrefix(strings.TrimSpace(s), "@"))
+	}
+	return names
+}
+
+// parseHashtags parses a comma-separated list of hashtags, with or without the
+// leading #
+func parseHashtags(v string) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	var tags []string
+	for _, s := range strings.Split(v, ",") {
+		tags = append(tags, strings.TrimPrefix(strings.TrimSpace(s), "#"))
+	}
+	return tags
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// newRunID returns a short random ID used to tell runs apart in logs
+func newRunID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newLogger(cfg config) (*zap.Logger, error) {
 	var lvl zapcore.Level
-	err := lvl.Set(logLevel)
+	err := lvl.Set(cfg.logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("setting log level to %s: %v", cfg.logLevel, err)
+	}
+	loc, err := time.LoadLocation(cfg.timezone)
 	if err != nil {
-		return nil, fmt.Errorf("setting log level to %s: %v", logLevel, err)
+		return nil, fmt.Errorf("loading timezone %s: %v", cfg.timezone, err)
+	}
+
+	zapConfig := zap.NewDevelopmentConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(lvl)
+	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		zapcore.ISO8601TimeEncoder(t.In(loc), enc)
+	}
+	if !cfg.noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stderr) {
+		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	var opts []zap.Option
+	if cfg.logSampleInitial > 0 {
+		opts = append(opts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newSampledInfoCore(core, cfg.logSampleInitial, cfg.logSampleThereafter)
+		}))
 	}
-	return zap.NewDevelopment(zap.IncreaseLevel(lvl))
+	return zapConfig.Build(opts...)
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/multierr"
+)
+
+// tweetAt returns a tweet with the given ID created at t
+func tweetAt(id int64, t time.Time) twitter.Tweet {
+	return twitter.Tweet{ID: id, CreatedAt: t.UTC().Format(time.RubyDate)}
+}
+
+func TestThinning(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	th := newThinning(loc)
+	tests := []struct {
+		tweet    twitter.Tweet
+		survivor bool
+	}{
+		{tweetAt(5, time.Date(2020, 3, 2, 12, 0, 0, 0, loc)), true},
+		{tweetAt(4, time.Date(2020, 3, 2, 8, 0, 0, 0, loc)), false},
+		// Still March 1st in loc, though March 2nd in UTC
+		{tweetAt(3, time.Date(2020, 3, 1, 22, 0, 0, 0, loc)), true},
+		{tweetAt(2, time.Date(2020, 3, 1, 1, 0, 0, 0, loc)), false},
+		{tweetAt(1, time.Date(2020, 2, 28, 23, 59, 0, 0, loc)), true},
+	}
+	for _, tt := range tests {
+		survivor, err := th.isSurvivor(tt.tweet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if survivor != tt.survivor {
+			t.Errorf("tweet %d: survivor = %v, want %v", tt.tweet.ID, survivor, tt.survivor)
+		}
+	}
+}
+
+func TestParseUsers(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantNames []string
+		wantIDs   []int64
+	}{
+		{in: ""},
+		{in: "mentor", wantNames: []string{"mentor"}},
+		{in: "@mentor, 12345", wantNames: []string{"mentor"}, wantIDs: []int64{12345}},
+		{in: "783214,@Twitter", wantNames: []string{"Twitter"}, wantIDs: []int64{783214}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			names, ids := parseUsers(tt.in)
+			if !reflect.DeepEqual(names, tt.wantNames) || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got %v %v, want %v %v", names, ids, tt.wantNames, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// validConfig returns a config that passes validation
+func validConfig() config {
+	return config{
+		consumerKey:      "key",
+		consumerSecret:   "secret",
+		oauthToken:       "token",
+		oauthTokenSecret: "token-secret",
+		retention:        retention{maxAge: 30 * day, keepLogic: keepAny},
+		errorMode:        errorModeFailFast,
+		deleteOrder:      orderNewest,
+		inFlight:         1,
+		pageSize:         maxPageSize,
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config)
+		want   []string
+	}{
+		{"valid", func(cfg *config) {}, nil},
+		{
+			name: "every missing flag at once",
+			modify: func(cfg *config) {
+				cfg.consumerSecret = ""
+				cfg.oauthToken = ""
+				cfg.retention.maxAge = 0
+			},
+			want: []string{"missing: -consumer-secret, -oauth-token, -max-age"},
+		},
+		{
+			name: "missing flags and bad values",
+			modify: func(cfg *config) {
+				cfg.oauthTokenSecret = ""
+				cfg.pageSize = 500
+				cfg.inFlight = 0
+			},
+			want: []string{
+				"missing: -oauth-token-secret",
+				"-in-flight must be at least 1",
+				"-page-size must be between 1 and 200",
+			},
+		},
+		{
+			name: "skipping verification",
+			modify: func(cfg *config) {
+				cfg.skipVerify = true
+			},
+			want: []string{"missing: -username, -account-id"},
+		},
+		{
+			name: "export only needs no max age",
+			modify: func(cfg *config) {
+				cfg.retention.maxAge = 0
+				cfg.exportOnly = true
+				cfg.backupFile = "backup.jsonl"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			var got []string
+			for _, err := range multierr.Errors(cfg.validate()) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// usersV2URL is the v2 users endpoint. The v1.1 user object doesn't include
+// the pinned tweet, so it's looked up here instead.
+const usersV2URL = "https://api.twitter.com/2/users/"
+
+// lookupPinnedTweet returns the ID of the account's pinned tweet, or 0 if it
+// hasn't pinned one.
+func lookupPinnedTweet(httpClient *http.Client, accountID string) (int64, error) {
+	var body struct {
+		Data struct {
+			PinnedTweetID string `json:"pinned_tweet_id"`
+		} `json:"data"`
+	}
+	_, err := call(func() (*http.Response, error) {
+		resp, err := httpClient.Get(usersV2URL + accountID + "?user.fields=pinned_tweet_id")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return resp, json.NewDecoder(resp.Body).Decode(&body)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if body.Data.PinnedTweetID == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(body.Data.PinnedTweetID, 10, 64)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// maxPlanSamples is how many tweets are shown for each month of the plan report
+const maxPlanSamples = 5
+
+// credentialFlags are left out of the policy shown in the plan report
+var credentialFlags = map[string]bool{
+	"consumer-key":       true,
+	"consumer-secret":    true,
+	"oauth-token":        true,
+	"oauth-token-secret": true,
+	"webhook-secret":     true,
+}
+
+// planSetting is a flag that shapes the retention policy, for the plan report
+type planSetting struct {
+	Name, Value string
+}
+
+// planPolicy returns the flags that were set on the command line or in the
+// config file, leaving out credentials. The policy from -policy-url is merged
+// in with remotePolicy.planSettings once it's fetched.
+func planPolicy(flagset *flag.FlagSet) []planSetting {
+	var policy []planSetting
+	flagset.Visit(func(f *flag.Flag) {
+		if credentialFlags[f.Name] {
+			return
+		}
+		policy = append(policy, planSetting{Name: f.Name, Value: f.Value.String()})
+	})
+	return policy
+}
+
+// plannedDeletion is a tweet the plan would delete
+type plannedDeletion struct {
+	ID   int64
+	Kind string
+	Text string
+}
+
+// planMonth is the deletions in one month
+type planMonth struct {
+	Month   string
+	Count   int
+	Samples []plannedDeletion
+}
+
+// planYear is the deletions in one year
+type planYear struct {
+	Year   string
+	Count  int
+	Months []*planMonth
+}
+
+// planReport collects what a dry run would delete, grouped by the month each
+// tweet was created, and renders it as HTML for -plan-html
+type planReport struct {
+	loc    *time.Location
+	policy []planSetting
+	months map[string]*planMonth
+	// kinds counts the deletions of each kind, which are kept apart since
+	// removing a favorite or retweet leaves the tweet itself alone
+	kinds map[string]int
+}
+
+// newPlanReport returns an empty report. Months are in loc.
+func newPlanReport(policy []planSetting, loc *time.Location) *planReport {
+	return &planReport{
+		loc:    loc,
+		policy: policy,
+		months: map[string]*planMonth{},
+		kinds:  map[string]int{},
+	}
+}
+
+// add records a tweet the plan would delete
+func (p *planReport) add(kind string, t twitter.Tweet) error {
+	createdAt, err := t.CreatedAtTime()
+	if err != nil {
+		return err
+	}
+	key := createdAt.In(p.loc).Format("2006-01")
+	m, ok := p.months[key]
+	if !ok {
+		m = &planMonth{Month: createdAt.In(p.loc).Format("January")}
+		p.months[key] = m
+	}
+	m.Count++
+	p.kinds[kind]++
+	if len(m.Samples) < maxPlanSamples {
+		m.Samples = append(m.Samples, plannedDeletion{ID: t.ID, Kind: kind, Text: t.Text})
+	}
+	return nil
+}
+
+// years returns the deletions grouped by year, newest first
+func (p *planReport) years() []*planYear {
+	keys := make([]string, 0, len(p.months))
+	for key := range p.months {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	var years []*planYear
+	for _, key := range keys {
+		year := key[:4]
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, &planYear{Year: year})
+		}
+		y := years[len(years)-1]
+		y.Count += p.months[key].Count
+		y.Months = append(y.Months, p.months[key])
+	}
+	return years
+}
+
+// writeFile renders the report to path, replacing it if it exists
+func (p *planReport) writeFile(path string, now time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = planTemplate.Execute(f, struct {
+		Generated string
+		Kinds     map[string]int
+		Policy    []planSetting
+		Years     []*planYear
+	}{
+		Generated: now.In(p.loc).Format("2 January 2006 15:04 MST"),
+		Kinds:     p.kinds,
+		Policy:    p.policy,
+		Years:     p.years(),
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var planTemplate = template.Must(template.New("plan").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>tprune plan</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: 0.2em 1em 0.2em 0; vertical-align: top; }
+.kind { color: #666; }
+</style>
+</head>
+<body>
+<h1>tprune plan</h1>
+<p>Planned {{.Generated}}.</p>
+<table>
+<tr><th>Tweets to delete</th><td>{{index .Kinds "Tweet"}}</td></tr>
+<tr><th>Retweets to undo</th><td>{{index .Kinds "Retweet"}}</td></tr>
+<tr><th>Favorites to remove</th><td>{{index .Kinds "Favorite"}}</td></tr>
+</table>
+
+<h2>Policy</h2>
+{{if .Policy}}<table>
+{{range .Policy}}<tr><th>-{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>{{else}}<p>Defaults only.</p>{{end}}
+
+{{range .Years}}<h2>{{.Year}}: {{.Count}}</h2>
+{{range .Months}}<h3>{{.Month}}: {{.Count}}</h3>
+<ul>
+{{range .Samples}}<li><span class="kind">{{.Kind}}</span> <a href="https://twitter.com/i/web/status/{{.ID}}">{{.ID}}</a>: {{.Text}}</li>
+{{end}}</ul>
+{{end}}{{end}}</body>
+</html>
+`))
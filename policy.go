@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// remotePolicy is the retention policy served from -policy-url:
+//
+//	{"maxAge": "90d", "keepIDs": ["1234"], "keepKeywords": ["#keep"]}
+//
+// Every field is optional.
+type remotePolicy struct {
+	MaxAge       string       `json:"maxAge"`
+	KeepIDs      []archiveInt `json:"keepIDs"`
+	KeepKeywords []string     `json:"keepKeywords"`
+}
+
+// fetchPolicy fetches and parses the retention policy at cfg.policyURL
+func fetchPolicy(ctx context.Context, cfg config) (remotePolicy, error) {
+	var pol remotePolicy
+	body, err := fetchRemote(ctx, cfg, cfg.policyURL)
+	if err != nil {
+		return pol, err
+	}
+	if err := json.Unmarshal(body, &pol); err != nil {
+		return pol, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	return pol, nil
+}
+
+// fetchKeepIDs fetches the list of tweet IDs to keep at cfg.keepIDsURL
+func fetchKeepIDs(ctx context.Context, cfg config) ([]int64, error) {
+	body, err := fetchRemote(ctx, cfg, cfg.keepIDsURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseIDList(body)
+}
+
+// maxRemoteSize is the largest response fetchRemote accepts
+const maxRemoteSize = 16 << 20
+
+// fetchRemote GETs a URL with the configured proxy and timeout, returning the
+// body of a 200 response
+func fetchRemote(ctx context.Context, cfg config, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport, Timeout: cfg.httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRemoteSize {
+		return nil, fmt.Errorf("%s responded with more than %d bytes", url, maxRemoteSize)
+	}
+	return body, nil
+}
+
+// parseIDList parses a list of tweet IDs that's either a JSON array of IDs, as
+// numbers or strings, or one ID or status URL per line. Blank lines and lines
+// starting with # are skipped.
+func parseIDList(data []byte) ([]int64, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var list []archiveInt
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, fmt.Errorf("invalid ID list: %w", err)
+		}
+		ids := make([]int64, len(list))
+		for i, id := range list {
+			ids[i] = int64(id)
+		}
+		return ids, nil
+	}
+
+	var ids []int64
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := parseTweetID(line)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// apply merges the policy into r. The policy's max age replaces the local one,
+// and its keep IDs and keywords are added to the local ones.
+func (pol remotePolicy) apply(r *retention) error {
+	if pol.MaxAge != "" {
+		maxAge, err := parseRetentionDuration(pol.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid maxAge: %w", err)
+		}
+		if maxAge <= 0 {
+			return fmt.Errorf("invalid maxAge %q: must be positive", pol.MaxAge)
+		}
+		r.maxAge = maxAge
+	}
+	for _, id := range pol.KeepIDs {
+		r.ids = append(r.ids, int64(id))
+	}
+	r.keywords = append(r.keywords, pol.KeepKeywords...)
+	return nil
+}
+
+// planSettings merges the policy into settings, the flags shown in the plan
+// report, the way apply merges it into the retention rules
+func (pol remotePolicy) planSettings(settings []planSetting) []planSetting {
+	merged := append([]planSetting(nil), settings...)
+	set := func(name, value string, add bool) {
+		for i := range merged {
+			if merged[i].Name == name {
+				if add && merged[i].Value != "" {
+					value = merged[i].Value + "," + value
+				}
+				merged[i].Value = value
+				return
+			}
+		}
+		merged = append(merged, planSetting{Name: name, Value: value})
+	}
+	if pol.MaxAge != "" {
+		set("max-age", pol.MaxAge, false)
+	}
+	if len(pol.KeepIDs) > 0 {
+		var ids []string
+		for _, id := range pol.KeepIDs {
+			ids = append(ids, strconv.FormatInt(int64(id), 10))
+		}
+		set("keep-ids", strings.Join(ids, ","), true)
+	}
+	if len(pol.KeepKeywords) > 0 {
+		set("keep-keywords", strings.Join(pol.KeepKeywords, ","), true)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemotePolicyPlanSettings(t *testing.T) {
+	local := []planSetting{
+		{Name: "keep-ids", Value: "1"},
+		{Name: "max-age", Value: "30d"},
+		{Name: "policy-url", Value: "https://example.com/policy.json"},
+	}
+	tests := []struct {
+		name string
+		pol  remotePolicy
+		want []planSetting
+	}{
+		{"empty", remotePolicy{}, local},
+		{
+			"replaces max age and adds keep IDs",
+			remotePolicy{MaxAge: "90d", KeepIDs: []archiveInt{2, 3}},
+			[]planSetting{
+				{Name: "keep-ids", Value: "1,2,3"},
+				{Name: "max-age", Value: "90d"},
+				{Name: "policy-url", Value: "https://example.com/policy.json"},
+			},
+		},
+		{
+			"adds keywords not set locally",
+			remotePolicy{KeepKeywords: []string{"#keep", "#pin"}},
+			[]planSetting{
+				{Name: "keep-ids", Value: "1"},
+				{Name: "keep-keywords", Value: "#keep,#pin"},
+				{Name: "max-age", Value: "30d"},
+				{Name: "policy-url", Value: "https://example.com/policy.json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pol.planSettings(local); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if local[1].Value != "30d" {
+		t.Errorf("settings were modified: %v", local)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// deletePool runs deletions concurrently with a limit on how many are in
+// flight. Deciding what to delete stays serial; only the requests overlap.
+type deletePool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	// mu guards the destroyer's stats and files, which in-flight deletions
+	// update when they finish, along with err.
+	mu  sync.Mutex
+	err error
+}
+
+// newDeletePool returns a pool that allows up to inFlight deletions at once
+func newDeletePool(inFlight int) *deletePool {
+	return &deletePool{sem: make(chan struct{}, inFlight)}
+}
+
+// lock locks the bookkeeping shared with in-flight deletions and returns the
+// function that unlocks it. Without a pool there's nothing to lock.
+func (d destroyer) lock() func() {
+	if d.pool == nil {
+		return func() {}
+	}
+	d.pool.mu.Lock()
+	return d.pool.mu.Unlock
+}
+
+// async runs a deletion for the tweet or message with the given ID. Without a
+// pool it runs immediately; with one it runs in the background once there's
+// room, and its error is handled as process would, stopping later deletions
+// unless errors are being collected. The first such error is returned by the next
+// call to async or wait. Nothing starts while the control file exists, once
+// the active window has passed, or once the run has been asked to stop.
+func (d destroyer) async(logger *zap.Logger, id int64, deletion func() error) error {
+	d.awaitControl(logger)
+	if d.window != nil && !d.window.contains(time.Now()) {
+		return stoppedError{errOutsideWindow}
+	}
+	if d.stopped() {
+		return stoppedError{errInterrupted}
+	}
+	if d.pool == nil {
+		return deletion()
+	}
+	if err := d.pool.firstErr(); err != nil {
+		return stoppedError{err}
+	}
+
+	d.pool.sem <- struct{}{}
+	d.pool.wg.Add(1)
+	go func() {
+		defer d.pool.wg.Done()
+		defer func() { <-d.pool.sem }()
+
+		err := func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return deletion()
+		}()
+		if err == nil {
+			return
+		}
+		if err := d.countError(logger, id, err); err != nil {
+			d.pool.mu.Lock()
+			if d.pool.err == nil {
+				d.pool.err = err
+			}
+			d.pool.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// wait waits for in-flight deletions to finish and returns the first error
+// that stopped the run, if any.
+func (d destroyer) wait() error {
+	if d.pool == nil {
+		return nil
+	}
+	d.pool.wg.Wait()
+	return d.pool.firstErr()
+}
+
+// stoppedError is returned by async for an error an earlier deletion already
+// counted, or a reason the run stopped that isn't a failure, so it isn't
+// counted as one.
+type stoppedError struct {
+	err error
+}
+
+func (e stoppedError) Error() string {
+	return e.err.Error()
+}
+
+func (e stoppedError) Unwrap() error {
+	return e.err
+}
+
+// firstErr returns the first error that stopped the run, if any
+func (p *deletePool) firstErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+func TestDestroyerTalliesKeptWhileDeleting(t *testing.T) {
+	// Each kept tweet is from a different year, so each tallies a new one
+	now := time.Now()
+	r := retention{maxAge: 30 * day}
+	var tweets []twitter.Tweet
+	for id := int64(1); id <= 1000; id++ {
+		r.ids = append(r.ids, id)
+		tweets = append(tweets, tweetAt(id, now.AddDate(-int(id), 0, 0)))
+	}
+	d := newDestroyer(nil, r, now, false)
+	d.pool = newDeletePool(4)
+	d.byYear = time.UTC
+
+	// Stand in for deletions finishing in the background while tweets are
+	// kept
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			unlock := d.lock()
+			d.stats.tweetsDeleted++
+			d.stats.tallyYear(2010, true)
+			unlock()
+		}
+	}()
+	for _, tweet := range tweets {
+		if err := d.destroyTweet(zap.NewNop(), tweet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if d.stats.tweetsKept != 1000 {
+		t.Errorf("kept %d tweets, want 1000", d.stats.tweetsKept)
+	}
+	if n := len(d.stats.years); n != 1000 {
+		t.Errorf("tallied %d years, want 1000", n)
+	}
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+var testAccount = twitter.User{ID: 42, IDStr: "42", ScreenName: "brett"}
+
+// newTestTimeline returns a fakeTwitter for testAccount with two recent
+// tweets and four older than 30 days, and a recent and an old favorite. The
+// IDs leave room below them, so paging past the oldest gets an empty page.
+func newTestTimeline(t *testing.T) *fakeTwitter {
+	now := time.Now()
+	f := newFakeTwitter(t, testAccount)
+	f.tweets = []twitter.Tweet{
+		tweetAt(106, now.Add(-time.Hour)),
+		tweetAt(105, now.Add(-2*day)),
+		tweetAt(104, now.Add(-40*day)),
+		tweetAt(103, now.Add(-50*day)),
+		tweetAt(102, now.Add(-60*day)),
+		tweetAt(101, now.Add(-70*day)),
+	}
+	f.favorites = []twitter.Tweet{
+		tweetAt(120, now.Add(-time.Hour)),
+		tweetAt(110, now.Add(-90*day)),
+	}
+	return f
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		pinnedID        int64
+		wantDestroyed   []int64
+		wantUnfavorited []int64
+	}{
+		{
+			name:            "old tweets and favorites",
+			args:            []string{"-max-age=30d"},
+			wantDestroyed:   []int64{104, 103, 102, 101},
+			wantUnfavorited: []int64{110},
+		},
+		{
+			name: "dry run",
+			args: []string{"-max-age=30d", "-dry-run"},
+		},
+		{
+			name:          "tweets only",
+			args:          []string{"-max-age=30d", "-tweets-only"},
+			wantDestroyed: []int64{104, 103, 102, 101},
+		},
+		{
+			name:            "keep ids",
+			args:            []string{"-max-age=30d", "-keep-ids=103"},
+			wantDestroyed:   []int64{104, 102, 101},
+			wantUnfavorited: []int64{110},
+		},
+		{
+			name:            "pinned tweet",
+			args:            []string{"-max-age=30d"},
+			pinnedID:        102,
+			wantDestroyed:   []int64{104, 103, 101},
+			wantUnfavorited: []int64{110},
+		},
+		{
+			name:            "oldest first",
+			args:            []string{"-max-age=30d", "-delete-order=oldest"},
+			wantDestroyed:   []int64{101, 102, 103, 104},
+			wantUnfavorited: []int64{110},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			f.pinnedID = tt.pinnedID
+			// Small pages make the run follow max_id across several
+			if err := runPrune(t, f, append(tt.args, "-page-size=2")...); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+			if !reflect.DeepEqual(f.unfavorited, tt.wantUnfavorited) {
+				t.Errorf("unfavorited %v, want %v", f.unfavorited, tt.wantUnfavorited)
+			}
+			// Three full pages and an empty one
+			if n := f.requests["/1.1/statuses/user_timeline.json"]; n != 4 {
+				t.Errorf("fetched %d timeline pages, want 4", n)
+			}
+		})
+	}
+}
+
+func TestPruneBacksOffWhenRateLimited(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{"timeline", "/1.1/statuses/user_timeline.json"},
+		{"favorites", "/1.1/favorites/list.json"},
+		{"destroy", "/1.1/statuses/destroy/:id.json"},
+		{"unfavorite", "/1.1/favorites/destroy.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			f.rateLimit(tt.endpoint, 1)
+			start := time.Now()
+			if err := runPrune(t, f, "-max-age=30d", "-keep-pinned=false"); err != nil {
+				t.Fatal(err)
+			}
+			if want := []int64{104, 103, 102, 101}; !reflect.DeepEqual(f.destroyed, want) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, want)
+			}
+			if want := []int64{110}; !reflect.DeepEqual(f.unfavorited, want) {
+				t.Errorf("unfavorited %v, want %v", f.unfavorited, want)
+			}
+			if f.limited[tt.endpoint] > 0 {
+				t.Errorf("%s was never rate limited", tt.endpoint)
+			}
+			// The reset is a whole second, so the wait is at least whatever
+			// was left of the current one
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("run took %s, want it to wait out only the one window", elapsed)
+			}
+		})
+	}
+}
+
+func TestPruneInFlightByYear(t *testing.T) {
+	// Kept tweets are tallied while deletions of the ones before them are
+	// still in flight
+	now := time.Now()
+	f := newFakeTwitter(t, testAccount)
+	var (
+		keepIDs     []string
+		wantDeleted []int64
+	)
+	for id := int64(200); id > 100; id-- {
+		f.tweets = append(f.tweets, tweetAt(id, now.Add(-time.Duration(300-id)*day)))
+		if id%2 == 0 {
+			keepIDs = append(keepIDs, strconv.FormatInt(id, 10))
+		} else {
+			wantDeleted = append(wantDeleted, id)
+		}
+	}
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+	err := runPrune(t, f, "-max-age=30d", "-keep-ids="+strings.Join(keepIDs, ","),
+		"-in-flight=4", "-report-by-year", "-summary-file="+summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deletions finish in any order
+	sort.Slice(f.destroyed, func(i, j int) bool { return f.destroyed[i] > f.destroyed[j] })
+	if !reflect.DeepEqual(f.destroyed, wantDeleted) {
+		t.Errorf("destroyed %v, want %v", f.destroyed, wantDeleted)
+	}
+
+	data, err := ioutil.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum summary
+	if err := json.Unmarshal(data, &sum); err != nil {
+		t.Fatal(err)
+	}
+	var deleted, kept int
+	for _, c := range sum.ByYear {
+		deleted += c.Deleted
+		kept += c.Kept
+	}
+	if deleted != 50 || kept != 50 {
+		t.Errorf("by year tallied %d deleted and %d kept, want 50 of each", deleted, kept)
+	}
+}
+
+// newOldTimeline returns a fakeTwitter for testAccount with tweets 101 to 120,
+// all old enough to delete
+func newOldTimeline(t *testing.T) *fakeTwitter {
+	now := time.Now()
+	f := newFakeTwitter(t, testAccount)
+	for id := int64(120); id > 100; id-- {
+		f.tweets = append(f.tweets, tweetAt(id, now.Add(-time.Duration(200-id)*day)))
+	}
+	return f
+}
+
+func TestPruneSavesProgressWhenInterrupted(t *testing.T) {
+	f := newOldTimeline(t)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	f.onDestroy = func(id int64) {
+		// Partway through the fourth page, with no checkpoint due
+		if id == 114 {
+			p, _ := os.FindProcess(os.Getpid())
+			if err := p.Signal(os.Interrupt); err != nil {
+				t.Error(err)
+			}
+			// Signals are handled asynchronously, so the deletion is held
+			// until the run has had time to see it
+			<-signals
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"-max-age=30d", "-tweets-only", "-keep-pinned=false", "-page-size=2",
+		"-state-file=" + statePath, "-checkpoint-interval=100"}
+	if err := runPrune(t, f, args...); !errors.Is(err, errInterrupted) {
+		t.Fatalf("got error %v, want %v", err, errInterrupted)
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The signal arrives while the run carries on, so it may get a little
+	// further, but never saves a page it didn't finish
+	if state.TweetMaxID < 110 || state.TweetMaxID > 114 || state.CompletedTweets {
+		t.Fatalf("saved %+v, want progress through the third or a later page", state)
+	}
+	for _, tweet := range f.tweets {
+		if tweet.ID > state.TweetMaxID {
+			t.Errorf("tweet %d is before the saved progress but wasn't deleted", tweet.ID)
+		}
+	}
+
+	f.onDestroy = nil
+	f.timelineMaxIDs = nil
+	if err := runPrune(t, f, args...); err != nil {
+		t.Fatal(err)
+	}
+	if f.timelineMaxIDs[0] != state.TweetMaxID {
+		t.Errorf("resumed from max_id %d, want %d", f.timelineMaxIDs[0], state.TweetMaxID)
+	}
+	if len(f.tweets) > 0 {
+		t.Errorf("%d tweets left after resuming, want none", len(f.tweets))
+	}
+}
+
+func TestPruneResumesAfterCrash(t *testing.T) {
+	// A run saved its progress after the second page, deleted three more
+	// pages and was killed before the next checkpoint
+	f := newOldTimeline(t)
+	f.tweets = f.tweets[10:]
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := (runState{TweetMaxID: 116}).save(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false", "-page-size=2",
+		"-state-file="+statePath, "-checkpoint-interval=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pages deleted since the checkpoint are gone from the timeline, so
+	// the run picks up with the first tweet it hadn't reached
+	if want := []int64{116, 108, 106, 104, 102, 100}; !reflect.DeepEqual(f.timelineMaxIDs, want) {
+		t.Errorf("fetched pages up to max_id %v, want %v", f.timelineMaxIDs, want)
+	}
+	if want := []int64{110, 109, 108, 107, 106, 105, 104, 103, 102, 101}; !reflect.DeepEqual(f.destroyed, want) {
+		t.Errorf("destroyed %v, want %v", f.destroyed, want)
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.isFresh() || state.LastCompletedAt.IsZero() {
+		t.Errorf("saved %+v after finishing, want only the completion time", state)
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	tests := []struct {
+		archive       string
+		wantDestroyed []int64
+	}{
+		// Tweets tagged #keep are judged by the archive's hashtags
+		{"testdata/archive-2019", []int64{106, 105, 1101}},
+		{"testdata/archive-2022", []int64{106, 105, 1204, 1203, 1201}},
+		{"testdata/archive-grailbird", []int64{106, 105, 1002, 1001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.archive, func(t *testing.T) {
+			// The timeline only reaches the two newest tweets; the rest are
+			// only in the archive
+			now := time.Now()
+			f := newFakeTwitter(t, testAccount)
+			f.tweets = []twitter.Tweet{
+				tweetAt(106, now.Add(-40*day)),
+				tweetAt(105, now.Add(-50*day)),
+			}
+			archived, err := readArchive(tt.archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, at := range archived {
+				tweet, err := at.toTweet()
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.tweets = append(f.tweets, tweet)
+			}
+			f.timelineLimit = 2
+
+			err = runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-keep-hashtags=keep", "-archive="+tt.archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+		})
+	}
+}
+
+func TestPruneExportOnly(t *testing.T) {
+	f := newTestTimeline(t)
+	backupPath := filepath.Join(t.TempDir(), "backup.jsonl")
+	// Without -max-age, and with rules that would otherwise cost passes of
+	// their own
+	err := runPrune(t, f, "-export-only", "-backup-file="+backupPath, "-page-size=2",
+		"-keep-first-tweet", "-keep-top-n=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.destroyed) > 0 || len(f.unfavorited) > 0 {
+		t.Errorf("destroyed %v and unfavorited %v, want nothing", f.destroyed, f.unfavorited)
+	}
+	ids, err := loadBackupIDs(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(f.tweets) {
+		t.Errorf("backed up %d tweets, want %d", len(ids), len(f.tweets))
+	}
+	for _, tweet := range f.tweets {
+		if !ids[tweet.ID] {
+			t.Errorf("tweet %d wasn't backed up", tweet.ID)
+		}
+	}
+	if n := f.requests["/1.1/statuses/user_timeline.json"]; n != 4 {
+		t.Errorf("fetched %d timeline pages, want only the export's 4", n)
+	}
+	if n := f.requests["/2/users/"+testAccount.IDStr]; n > 0 {
+		t.Errorf("looked up the pinned tweet %d times, want none", n)
+	}
+}
+
+func TestPruneErrorMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantDestroyed []int64
+	}{
+		{"fail fast", nil, []int64{104}},
+		{"best effort", []string{"-error-mode=best-effort"}, []int64{104, 102, 101}},
+		{"continue on error", []string{"-continue-on-error"}, []int64{104, 102, 101}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			f.broken = map[int64]bool{103: true}
+			err := runPrune(t, f, append(tt.args, "-max-age=30d", "-tweets-only", "-keep-pinned=false")...)
+			if err == nil {
+				t.Error("got no error, want the failed deletion reported")
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+		})
+	}
+}
+
+func TestPruneProtectedAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"timeline and favorites", nil},
+		{"skips search", []string{"-use-search"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			f.account.Protected = true
+			if err := runPrune(t, f, append(tt.args, "-max-age=30d", "-keep-pinned=false")...); err != nil {
+				t.Fatal(err)
+			}
+			if want := []int64{104, 103, 102, 101}; !reflect.DeepEqual(f.destroyed, want) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, want)
+			}
+			if want := []int64{110}; !reflect.DeepEqual(f.unfavorited, want) {
+				t.Errorf("unfavorited %v, want %v", f.unfavorited, want)
+			}
+			if n := f.requests["/1.1/search/tweets.json"]; n > 0 {
+				t.Errorf("searched %d times, want none for a protected account", n)
+			}
+		})
+	}
+}
+
+func TestPruneArchiveZeroEngagement(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		// favorited holds the current favorite counts, which the archive's
+		// are missing or out of date for
+		favorited     map[int64]int
+		gone          []int64
+		wantDestroyed []int64
+	}{
+		{
+			name:          "counts missing",
+			archive:       "testdata/archive-grailbird",
+			favorited:     map[int64]int{1002: 5},
+			gone:          []int64{1001},
+			wantDestroyed: []int64{106, 105, 1003},
+		},
+		{
+			name:          "counts out of date",
+			archive:       "testdata/archive-2019",
+			favorited:     map[int64]int{1101: 3, 1102: 0},
+			wantDestroyed: []int64{106, 105, 1102},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			f := newFakeTwitter(t, testAccount)
+			f.tweets = []twitter.Tweet{
+				tweetAt(106, now.Add(-40*day)),
+				tweetAt(105, now.Add(-50*day)),
+			}
+			archived, err := readArchive(tt.archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, at := range archived {
+				tweet, err := at.toTweet()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if containsID(tt.gone, tweet.ID) {
+					continue
+				}
+				tweet.FavoriteCount = tt.favorited[tweet.ID]
+				f.tweets = append(f.tweets, tweet)
+			}
+			f.timelineLimit = 2
+
+			err = runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-only-zero-engagement", "-archive="+tt.archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+			if f.requests["/1.1/statuses/lookup.json"] == 0 {
+				t.Error("didn't look up the archived tweets' current counts")
+			}
+		})
+	}
+}
+
+func TestPruneTrimUser(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"trimmed", nil, "true"},
+		{"full users for self quotes", []string{"-keep-self-quotes"}, "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			if err := runPrune(t, f, append(tt.args, "-max-age=30d", "-dry-run")...); err != nil {
+				t.Fatal(err)
+			}
+			if len(f.timelineTrimUser) == 0 {
+				t.Fatal("never fetched the timeline")
+			}
+			for _, trim := range f.timelineTrimUser {
+				if trim != tt.want {
+					t.Errorf("fetched the timeline with trim_user %v, want all %s", f.timelineTrimUser, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPruneConfirmThreshold(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantErr       bool
+		wantDestroyed []int64
+	}{
+		// The timeline only has two tweets to delete, but the archive has
+		// three more
+		{"archive over threshold", []string{"-confirm-threshold=4"}, true, nil},
+		{"under threshold", []string{"-confirm-threshold=5"}, false, []int64{106, 105, 1204, 1203, 1201}},
+		{"confirmed", []string{"-confirm-threshold=4", "-yes"}, false, []int64{106, 105, 1204, 1203, 1201}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			f := newFakeTwitter(t, testAccount)
+			f.tweets = []twitter.Tweet{
+				tweetAt(106, now.Add(-40*day)),
+				tweetAt(105, now.Add(-50*day)),
+			}
+			archived, err := readArchive("testdata/archive-2022")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, at := range archived {
+				tweet, err := at.toTweet()
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.tweets = append(f.tweets, tweet)
+			}
+			f.timelineLimit = 2
+
+			err = runPrune(t, f, append(tt.args, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-archive=testdata/archive-2022")...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+		})
+	}
+}
+
+func TestPrunePlanPolicy(t *testing.T) {
+	f := newTestTimeline(t)
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"maxAge": "45d", "keepIDs": ["101"]}`))
+	}))
+	defer policy.Close()
+	planPath := filepath.Join(t.TempDir(), "plan.html")
+
+	if err := runPrune(t, f, "-max-age=30d", "-keep-ids=102", "-policy-url="+policy.URL,
+		"-dry-run", "-plan-html="+planPath); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The policy shown is the one the plan was made with
+	for _, want := range []string{
+		"<th>-max-age</th><td>45d</td>",
+		"<th>-keep-ids</th><td>102,101</td>",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("plan doesn't contain %q:\n%s", want, b)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// query is a boolean expression over substrings of a tweet's text, as given
+// to -match-query
+type query interface {
+	// matches reports whether the lowercased text satisfies the query
+	matches(text string) bool
+}
+
+// termQuery matches text containing the term
+type termQuery string
+
+func (q termQuery) matches(text string) bool {
+	return strings.Contains(text, string(q))
+}
+
+// andQuery matches text both sides match
+type andQuery struct {
+	left, right query
+}
+
+func (q andQuery) matches(text string) bool {
+	return q.left.matches(text) && q.right.matches(text)
+}
+
+// orQuery matches text either side matches
+type orQuery struct {
+	left, right query
+}
+
+func (q orQuery) matches(text string) bool {
+	return q.left.matches(text) || q.right.matches(text)
+}
+
+// notQuery matches text the inner query doesn't
+type notQuery struct {
+	q query
+}
+
+func (q notQuery) matches(text string) bool {
+	return !q.q.matches(text)
+}
+
+// queryToken is a lexical token of a query. Quoted tokens are always terms,
+// even if they spell an operator.
+type queryToken struct {
+	value  string
+	quoted bool
+}
+
+// is reports whether the token is the unquoted operator or parenthesis op
+func (t queryToken) is(op string) bool {
+	return !t.quoted && t.value == op
+}
+
+// parseQuery parses a query like `acme AND NOT "acme labs" OR (foo bar)`.
+//
+// Terms are words or double-quoted phrases and match case-insensitively
+// anywhere in the text. NOT binds tightest, then AND, then OR; terms next to
+// each other are ANDed, and parentheses group.
+func parseQuery(v string) (query, error) {
+	tokens, err := lexQuery(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", v, err)
+	}
+	p := &queryParser{tokens: tokens}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", v, err)
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("invalid query %q: unexpected %q", v, p.peek().value)
+	}
+	return q, nil
+}
+
+// lexQuery splits a query into tokens
+func lexQuery(v string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(v)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{value: string(r)})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			tokens = append(tokens, queryToken{value: string(runes[i+1 : end]), quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, queryToken{value: string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser over query tokens
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+// parseOr parses and-expressions separated by OR
+func (p *queryParser) parseOr() (query, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && p.peek().is("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orQuery{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses unary expressions separated by AND or nothing at all
+func (p *queryParser) parseAnd() (query, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && !p.peek().is("OR") && !p.peek().is(")") {
+		if p.peek().is("AND") {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andQuery{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses a term, a NOT or a parenthesized expression
+func (p *queryParser) parseUnary() (query, error) {
+	if p.done() {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	t := p.peek()
+	p.pos++
+	switch {
+	case t.is("NOT"):
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notQuery{q: q}, nil
+	case t.is("("):
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || !p.peek().is(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return q, nil
+	case t.is(")"), t.is("AND"), t.is("OR"):
+		return nil, fmt.Errorf("unexpected %q", t.value)
+	case t.value == "":
+		return nil, fmt.Errorf("empty term")
+	}
+	return termQuery(strings.ToLower(t.value)), nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// tweetRecord is what the retention rules need to know about a tweet. The
+// rules only see tweets through it, so they don't depend on go-twitter or on
+// where tweets come from. Tweets from the API are adapted with apiTweet;
+// other sources, like a data export or the v2 API, provide their own.
+type tweetRecord interface {
+	tweetID() int64
+	createdAt() (time.Time, error)
+	text() string
+	// lang is Twitter's detection of the tweet's language
+	lang() string
+	// links are the t.co links in the text along with their destinations
+	links() []link
+	// mentions are the screen names mentioned
+	mentions() []string
+	// hashtags are the hashtags used, without the #
+	hashtags() []string
+	// replyTo is who the tweet replies to, or zeros if it isn't a reply
+	replyTo() (userID int64, screenName string)
+	geotagged() bool
+	sensitive() bool
+	// quotedUserID is the author of the quoted tweet, or 0 if there isn't one
+	// or the author isn't known
+	quotedUserID() int64
+	// textOnly reports whether the tweet has no media, links or quoted tweet
+	textOnly() bool
+	// hasMedia reports whether the tweet has photos, videos or GIFs attached
+	hasMedia() bool
+	favoriteCount() int
+	retweetCount() int
+}
+
+// link is a shortened link and where it leads
+type link struct {
+	url, expandedURL string
+}
+
+// retention is the retention policy
+type retention struct {
+	ids      []int64
+	keywords []string
+	langs    []string
+	maxAge   time.Duration
+
+	// mediaMaxAge, when set, replaces maxAge for tweets with media attached
+	mediaMaxAge time.Duration
+
+	// mentions keeps tweets mentioning any of these screen names. Both the
+	// timeline and favorites include user mention entities.
+	mentions []string
+
+	// hashtags keeps tweets using any of these hashtags, matched against the
+	// hashtag entities rather than the text so #project doesn't match
+	// #projected
+	hashtags []string
+
+	// repliesToNames and repliesToIDs keep replies to these accounts. The
+	// reply fields are part of the tweet, so trim_user doesn't drop them.
+	repliesToNames []string
+	repliesToIDs   []int64
+
+	// idRanges keeps tweets whose IDs fall in any of these ranges. IDs are
+	// roughly time-ordered, so ranges are windows of time.
+	idRanges []idRange
+
+	// onlyIDs, when set, limits deletion to these IDs
+	onlyIDs []int64
+
+	// matchQuery, when set, limits deletion to tweets whose text satisfies it
+	matchQuery query
+
+	// deleteIfNoneOf, when set, limits deletion to tweets containing none of
+	// these substrings. Unlike keywords it doesn't take part in keepLogic: a
+	// tweet containing one is always kept.
+	deleteIfNoneOf []string
+
+	// keepGeotagged keeps tweets that have a place or coordinates attached.
+	// The timeline always includes these fields.
+	keepGeotagged bool
+
+	// keepSensitive keeps tweets Twitter marked as possibly sensitive
+	keepSensitive bool
+
+	// minLength keeps tweets with at least this many characters (runes, not
+	// bytes) when non-zero
+	minLength int
+
+	// keepSelfQuotes keeps tweets quoting one of accountID's tweets. It reads
+	// the quoted tweet's user, so the timeline is fetched with full users.
+	keepSelfQuotes bool
+	accountID      int64
+
+	// onlyText keeps tweets with media, links or a quoted tweet. Polls and
+	// cards show up as links.
+	onlyText bool
+
+	// onlyZeroEngagement keeps tweets that have been favorited or retweeted.
+	// Retweets and favorites carry the original tweet's counts.
+	onlyZeroEngagement bool
+
+	// keepRatio is the fraction of otherwise deleted tweets to keep, chosen
+	// by hashing their IDs
+	keepRatio float64
+
+	// expandURLs replaces t.co links with their destinations before matching
+	// keywords. Both the timeline and favorites include URL entities.
+	expandURLs bool
+
+	// verifiedReplies, when set, keeps replies to verified users. Tweets only
+	// carry the ID of the user they reply to, so it looks the user up.
+	verifiedReplies userVerifier
+
+	// keepLogic is how the keep rules other than the keep IDs combine:
+	// keepAny (the default) or keepAll
+	keepLogic string
+
+	// matchedIDs, when non-nil, records which keep IDs were seen
+	matchedIDs map[int64]bool
+}
+
+// isTombstoned determines whether or not a tweet should be deleted. When it
+// shouldn't, reason names the rule keeping it, usually after its flag: for
+// example "-max-age" for a tweet that's too young, or "-keep-keywords". Tweets
+// kept by several keep rules list them all, separated by "+".
+func (r retention) isTombstoned(logger *zap.Logger, t tweetRecord, now time.Time) (evict bool, reason string, err error) {
+	createdAt, err := t.createdAt()
+	if err != nil {
+		return false, "", err
+	}
+	id := t.tweetID()
+
+	if r.matchedIDs != nil && containsID(r.ids, id) {
+		r.matchedIDs[id] = true
+	}
+
+	if maxAge := r.maxAgeFor(t); !olderThan(createdAt, now, maxAge) {
+		if maxAge != r.maxAge {
+			return false, "-media-max-age", nil
+		}
+		return false, "-max-age", nil
+	}
+	if len(r.onlyIDs) > 0 && !containsID(r.onlyIDs, id) {
+		return false, "-only-ids", nil
+	}
+	if r.matchQuery != nil && !r.matchQuery.matches(strings.ToLower(r.matchText(t))) {
+		return false, "-match-query", nil
+	}
+	if len(r.deleteIfNoneOf) > 0 {
+		text := r.matchText(t)
+		for _, s := range r.deleteIfNoneOf {
+			if strings.Contains(text, s) {
+				return false, "-delete-if-none-of", nil
+			}
+		}
+	}
+	if containsID(r.ids, id) {
+		return false, "-keep-ids", nil
+	}
+	for _, rng := range r.idRanges {
+		if rng.contains(id) {
+			return false, "-keep-id-ranges", nil
+		}
+	}
+	conds, err := r.keepConditions(t)
+	if err != nil {
+		return false, "", err
+	}
+	if r.combineKeeps(conds) {
+		return false, keepReason(conds), nil
+	}
+	if r.keepRatio > 0 && idFraction(id) < r.keepRatio {
+		return false, "-keep-ratio", nil
+	}
+	return true, "", nil
+}
+
+// keepCondition is whether a keep rule, named by its flag, matched a tweet
+type keepCondition struct {
+	rule    string
+	matched bool
+}
+
+// keepConditions evaluates each of the active keep rules other than the keep
+// IDs against a tweet, reporting whether each one matched.
+func (r retention) keepConditions(t tweetRecord) ([]keepCondition, error) {
+	var conds []keepCondition
+	if len(r.keywords) > 0 {
+		text := r.matchText(t)
+		matched := false
+		for _, keyword := range r.keywords {
+			matched = matched || strings.Contains(text, keyword)
+		}
+		conds = append(conds, keepCondition{"-keep-keywords", matched})
+	}
+	// Lang is Twitter's own detection of the tweet's language. It's not
+	// always accurate and will be "und" when Twitter couldn't decide.
+	if len(r.langs) > 0 {
+		matched := false
+		for _, lang := range r.langs {
+			matched = matched || strings.EqualFold(t.lang(), lang)
+		}
+		conds = append(conds, keepCondition{"-keep-lang", matched})
+	}
+	if len(r.mentions) > 0 {
+		matched := false
+		for _, mention := range t.mentions() {
+			for _, name := range r.mentions {
+				matched = matched || strings.EqualFold(mention, name)
+			}
+		}
+		conds = append(conds, keepCondition{"-keep-mentions", matched})
+	}
+	if len(r.hashtags) > 0 {
+		matched := false
+		for _, tag := range t.hashtags() {
+			for _, keep := range r.hashtags {
+				matched = matched || strings.EqualFold(tag, keep)
+			}
+		}
+		conds = append(conds, keepCondition{"-keep-hashtags", matched})
+	}
+	if len(r.repliesToIDs) > 0 || len(r.repliesToNames) > 0 {
+		replyToID, replyToName := t.replyTo()
+		matched := replyToID != 0 && containsID(r.repliesToIDs, replyToID)
+		for _, name := range r.repliesToNames {
+			matched = matched || replyToName != "" && strings.EqualFold(replyToName, name)
+		}
+		conds = append(conds, keepCondition{"-keep-replies-to", matched})
+	}
+	if r.keepGeotagged {
+		conds = append(conds, keepCondition{"-keep-geotagged", t.geotagged()})
+	}
+	if r.keepSensitive {
+		conds = append(conds, keepCondition{"-keep-sensitive", t.sensitive()})
+	}
+	if r.minLength > 0 {
+		conds = append(conds, keepCondition{"-keep-min-length", utf8.RuneCountInString(t.text()) >= r.minLength})
+	}
+	if r.keepSelfQuotes {
+		conds = append(conds, keepCondition{"-keep-self-quotes", t.quotedUserID() != 0 && t.quotedUserID() == r.accountID})
+	}
+	if r.onlyText {
+		conds = append(conds, keepCondition{"-only-text", !t.textOnly()})
+	}
+	if r.onlyZeroEngagement {
+		conds = append(conds, keepCondition{"-only-zero-engagement", t.favoriteCount() > 0 || t.retweetCount() > 0})
+	}
+	if r.verifiedReplies != nil {
+		matched := false
+		if replyToID, _ := t.replyTo(); replyToID != 0 {
+			verified, err := r.verifiedReplies.isVerified(replyToID)
+			if err != nil {
+				return nil, err
+			}
+			matched = verified
+		}
+		conds = append(conds, keepCondition{"-keep-verified-replies", matched})
+	}
+	return conds, nil
+}
+
+// combineKeeps reports whether the keep conditions keep a tweet under the
+// keep logic: any one matching keeps it, or with keepAll, all of them must.
+func (r retention) combineKeeps(conds []keepCondition) bool {
+	if r.keepLogic == keepAll {
+		for _, c := range conds {
+			if !c.matched {
+				return false
+			}
+		}
+		return len(conds) > 0
+	}
+	for _, c := range conds {
+		if c.matched {
+			return true
+		}
+	}
+	return false
+}
+
+// keepReason joins the rules of the conditions that matched
+func keepReason(conds []keepCondition) string {
+	var rules []string
+	for _, c := range conds {
+		if c.matched {
+			rules = append(rules, c.rule)
+		}
+	}
+	return strings.Join(rules, "+")
+}
+
+// userVerifier reports whether users are verified
+type userVerifier interface {
+	isVerified(userID int64) (bool, error)
+}
+
+// Keep logics
+const (
+	keepAny = "any"
+	keepAll = "all"
+)
+
+// idRange is an inclusive range of tweet IDs
+type idRange struct {
+	min, max int64
+}
+
+// contains reports whether id is in the range
+func (r idRange) contains(id int64) bool {
+	return id >= r.min && id <= r.max
+}
+
+// idFraction maps a tweet ID to a number in [0, 1) by hashing it, so the same
+// tweets are picked by -keep-ratio on every run.
+func idFraction(id int64) float64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(id))
+	h := fnv.New64a()
+	h.Write(b[:])
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// fullUserRules returns the active rules that read users embedded in a
+// tweet, which trim_user cuts down. Fetching with full users makes sure
+// they're there for these rules.
+func (r retention) fullUserRules() []string {
+	var rules []string
+	if r.keepSelfQuotes {
+		rules = append(rules, "-keep-self-quotes")
+	}
+	return rules
+}
+
+// engagementRules returns the active rules that read favorite or retweet
+// counts. An archive's counts are out of date or missing, so archived tweets
+// are looked up for their current ones when any of these are in use.
+func (r retention) engagementRules() []string {
+	var rules []string
+	if r.onlyZeroEngagement {
+		rules = append(rules, "-only-zero-engagement")
+	}
+	return rules
+}
+
+// needsFullUser reports whether tweets must be fetched without trim_user
+func (r retention) needsFullUser() bool {
+	return len(r.fullUserRules()) > 0
+}
+
+// matchText returns the text of a tweet that keywords are matched against
+func (r retention) matchText(t tweetRecord) string {
+	text := t.text()
+	if !r.expandURLs {
+		return text
+	}
+	for _, l := range t.links() {
+		if l.url == "" || l.expandedURL == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, l.url, l.expandedURL)
+	}
+	return text
+}
+
+// maxAgeFor returns the max age that applies to a tweet
+func (r retention) maxAgeFor(t tweetRecord) time.Duration {
+	if r.mediaMaxAge > 0 && t.hasMedia() {
+		return r.mediaMaxAge
+	}
+	return r.maxAge
+}
+
+// longestMaxAge returns the longest max age that applies to any tweet
+func (r retention) longestMaxAge() time.Duration {
+	if r.mediaMaxAge > r.maxAge {
+		return r.mediaMaxAge
+	}
+	return r.maxAge
+}
+
+// olderThan reports whether something created at createdAt is strictly older
+// than maxAge at now, so a tweet exactly maxAge old is kept
+func olderThan(createdAt, now time.Time, maxAge time.Duration) bool {
+	return now.Sub(createdAt) > maxAge
+}
+
+// containsID reports whether id is in ids
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// unmatchedIDs returns the keep IDs that were never seen by isTombstoned. It
+// returns nil unless matchedIDs is set.
+func (r retention) unmatchedIDs() []int64 {
+	if r.matchedIDs == nil {
+		return nil
+	}
+	var unmatched []int64
+	for _, id := range r.ids {
+		if !r.matchedIDs[id] {
+			unmatched = append(unmatched, id)
+		}
+	}
+	return unmatched
+}
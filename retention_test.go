@@ -0,0 +1,290 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+func TestOlderThan(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	maxAge := 30 * day
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      bool
+	}{
+		{"just under", now.Add(-maxAge + time.Second), false},
+		{"exactly at", now.Add(-maxAge), false},
+		{"just over", now.Add(-maxAge - time.Second), true},
+		{"in the future", now.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := olderThan(tt.createdAt, now, maxAge); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTombstonedMaxAge(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := retention{maxAge: 30 * day, mediaMaxAge: 90 * day}
+	withMedia := func(tweet twitter.Tweet) twitter.Tweet {
+		tweet.Entities = &twitter.Entities{Media: []twitter.MediaEntity{{}}}
+		return tweet
+	}
+	tests := []struct {
+		name       string
+		tweet      twitter.Tweet
+		wantEvict  bool
+		wantReason string
+	}{
+		{"just under", tweetAt(1, now.Add(-30*day+time.Second)), false, "-max-age"},
+		{"exactly at", tweetAt(1, now.Add(-30*day)), false, "-max-age"},
+		{"just over", tweetAt(1, now.Add(-30*day-time.Second)), true, ""},
+		{"media exactly at", withMedia(tweetAt(1, now.Add(-90*day))), false, "-media-max-age"},
+		{"media just over", withMedia(tweetAt(1, now.Add(-90*day-time.Second))), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evict, reason, err := r.isTombstoned(zap.NewNop(), apiTweet{tt.tweet}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evict != tt.wantEvict || reason != tt.wantReason {
+				t.Errorf("got %t %q, want %t %q", evict, reason, tt.wantEvict, tt.wantReason)
+			}
+		})
+	}
+}
+
+// keepRuleTest is a tweet old enough to delete and whether a keep rule still
+// lets it be deleted
+type keepRuleTest struct {
+	name      string
+	tweet     twitter.Tweet
+	wantEvict bool
+}
+
+// testKeepRule runs the tests against r, with a max age all of the tweets are
+// past
+func testKeepRule(t *testing.T, r retention, tests []keepRuleTest) {
+	t.Helper()
+	now := time.Now()
+	r.maxAge = 30 * day
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweet := tt.tweet
+			tweet.CreatedAt = now.Add(-40 * day).UTC().Format(time.RubyDate)
+			evict, _, err := r.isTombstoned(zap.NewNop(), apiTweet{tweet}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evict != tt.wantEvict {
+				t.Errorf("got evict %t, want %t", evict, tt.wantEvict)
+			}
+		})
+	}
+}
+
+func TestKeepLang(t *testing.T) {
+	testKeepRule(t, retention{langs: parseKeepLangs("ja, pt-BR")}, []keepRuleTest{
+		{"kept language", twitter.Tweet{Lang: "ja"}, false},
+		{"kept language with a region", twitter.Tweet{Lang: "pt-br"}, false},
+		{"other language", twitter.Tweet{Lang: "en"}, true},
+		{"undetermined", twitter.Tweet{Lang: "und"}, true},
+		{"no language", twitter.Tweet{}, true},
+	})
+}
+
+func TestKeepGeotagged(t *testing.T) {
+	testKeepRule(t, retention{keepGeotagged: true}, []keepRuleTest{
+		{"place", twitter.Tweet{Place: &twitter.Place{FullName: "Austin, TX"}}, false},
+		{"coordinates", twitter.Tweet{Coordinates: &twitter.Coordinates{Type: "Point"}}, false},
+		{"not geotagged", twitter.Tweet{}, true},
+	})
+}
+
+func TestKeepMinLength(t *testing.T) {
+	testKeepRule(t, retention{minLength: 5}, []keepRuleTest{
+		{"long enough", twitter.Tweet{Text: "hello"}, false},
+		{"too short", twitter.Tweet{Text: "lol"}, true},
+		// Five runes, though many more bytes
+		{"multibyte", twitter.Tweet{Text: "こんにちは"}, false},
+		{"emoji", twitter.Tweet{Text: "🎉🎉🎉🎉🎉"}, false},
+		// More than five bytes, but four runes
+		{"short multibyte", twitter.Tweet{Text: "はい🎉!"}, true},
+		{"empty", twitter.Tweet{}, true},
+	})
+}
+
+func TestExpandURLs(t *testing.T) {
+	linked := func(text string, urls ...twitter.URLEntity) twitter.Tweet {
+		return twitter.Tweet{Text: text, Entities: &twitter.Entities{Urls: urls}}
+	}
+	github := twitter.URLEntity{URL: "https://t.co/abc", ExpandedURL: "https://github.com/brettbuddin/tprune"}
+	unexpanded := twitter.URLEntity{URL: "https://t.co/abc"}
+	t.Run("expanded", func(t *testing.T) {
+		testKeepRule(t, retention{keywords: []string{"github.com"}, expandURLs: true}, []keepRuleTest{
+			{"link to the keyword", linked("new release https://t.co/abc", github), false},
+			{"link elsewhere", linked("lunch https://t.co/xyz", twitter.URLEntity{URL: "https://t.co/xyz", ExpandedURL: "https://example.com"}), true},
+			{"no expanded url", linked("new release https://t.co/abc", unexpanded), true},
+			{"keyword in text", twitter.Tweet{Text: "see github.com"}, false},
+		})
+	})
+	t.Run("not expanded", func(t *testing.T) {
+		testKeepRule(t, retention{keywords: []string{"github.com"}}, []keepRuleTest{
+			{"link to the keyword", linked("new release https://t.co/abc", github), true},
+			{"keyword in text", twitter.Tweet{Text: "see github.com"}, false},
+		})
+	})
+	t.Run("delete if none of", func(t *testing.T) {
+		testKeepRule(t, retention{deleteIfNoneOf: []string{"github.com"}, expandURLs: true}, []keepRuleTest{
+			{"link to the marker", linked("new release https://t.co/abc", github), false},
+			{"no marker", twitter.Tweet{Text: "lunch"}, true},
+		})
+	})
+}
+
+func TestKeepMentions(t *testing.T) {
+	mentioning := func(names ...string) twitter.Tweet {
+		var mentions []twitter.MentionEntity
+		for _, name := range names {
+			mentions = append(mentions, twitter.MentionEntity{ScreenName: name})
+		}
+		return twitter.Tweet{Entities: &twitter.Entities{UserMentions: mentions}}
+	}
+	testKeepRule(t, retention{mentions: parseScreenNames("@Partner, friend")}, []keepRuleTest{
+		{"kept handle", mentioning("partner"), false},
+		{"kept handle in another case", mentioning("FRIEND"), false},
+		{"kept among others", mentioning("someone", "Partner"), false},
+		{"other handle", mentioning("someone"), true},
+		// Only the mention entities count, not the text
+		{"handle in text", twitter.Tweet{Text: "hi @partner"}, true},
+		{"no mentions", twitter.Tweet{}, true},
+	})
+}
+
+func TestKeepSensitive(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     retention
+		tests []keepRuleTest
+	}{
+		{"set", retention{keepSensitive: true}, []keepRuleTest{
+			{"sensitive", twitter.Tweet{PossiblySensitive: true}, false},
+			{"not sensitive", twitter.Tweet{}, true},
+		}},
+		{"unset", retention{}, []keepRuleTest{
+			{"sensitive", twitter.Tweet{PossiblySensitive: true}, true},
+			{"not sensitive", twitter.Tweet{}, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testKeepRule(t, tt.r, tt.tests)
+		})
+	}
+}
+
+func TestKeepSelfQuotes(t *testing.T) {
+	quoting := func(userID int64) twitter.Tweet {
+		return twitter.Tweet{QuotedStatus: &twitter.Tweet{ID: 1, User: &twitter.User{ID: userID}}}
+	}
+	testKeepRule(t, retention{keepSelfQuotes: true, accountID: testAccount.ID}, []keepRuleTest{
+		{"quote of own tweet", quoting(testAccount.ID), false},
+		{"quote of another's tweet", quoting(7), true},
+		{"quoted author unknown", twitter.Tweet{QuotedStatus: &twitter.Tweet{ID: 1}}, true},
+		{"not a quote", twitter.Tweet{}, true},
+	})
+}
+
+func TestKeepRepliesTo(t *testing.T) {
+	names, ids := parseUsers("@Mentor, 12345")
+	testKeepRule(t, retention{repliesToNames: names, repliesToIDs: ids}, []keepRuleTest{
+		{"reply to kept name", twitter.Tweet{InReplyToScreenName: "mentor", InReplyToUserID: 7}, false},
+		{"reply to kept id", twitter.Tweet{InReplyToScreenName: "renamed", InReplyToUserID: 12345}, false},
+		{"reply to other", twitter.Tweet{InReplyToScreenName: "someone", InReplyToUserID: 7}, true},
+		{"not a reply", twitter.Tweet{}, true},
+	})
+}
+
+func TestDeleteIfNoneOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     retention
+		tests []keepRuleTest
+	}{
+		{"alone", retention{deleteIfNoneOf: parseKeepKeywords("golang,rust")}, []keepRuleTest{
+			{"contains one", twitter.Tweet{Text: "learning golang"}, false},
+			{"contains none", twitter.Tweet{Text: "lunch"}, true},
+		}},
+		// A tweet is deleted only if it contains none of the markers and no
+		// keyword keeps it
+		{"with keep keywords", retention{deleteIfNoneOf: parseKeepKeywords("golang"), keywords: parseKeepKeywords("family")}, []keepRuleTest{
+			{"contains a marker", twitter.Tweet{Text: "learning golang"}, false},
+			{"contains a keyword", twitter.Tweet{Text: "family dinner"}, false},
+			{"contains neither", twitter.Tweet{Text: "lunch"}, true},
+		}},
+		// Markers keep tweets even when every keep rule must match
+		{"with keep all", retention{deleteIfNoneOf: parseKeepKeywords("golang"), keywords: parseKeepKeywords("family"), keepLogic: keepAll}, []keepRuleTest{
+			{"contains a marker", twitter.Tweet{Text: "learning golang"}, false},
+			{"contains neither", twitter.Tweet{Text: "lunch"}, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testKeepRule(t, tt.r, tt.tests)
+		})
+	}
+}
+
+func TestOnlyZeroEngagement(t *testing.T) {
+	testKeepRule(t, retention{onlyZeroEngagement: true}, []keepRuleTest{
+		{"favorited", twitter.Tweet{FavoriteCount: 1}, false},
+		{"retweeted", twitter.Tweet{RetweetCount: 1}, false},
+		{"no engagement", twitter.Tweet{}, true},
+	})
+}
+
+func TestNeedsFullUser(t *testing.T) {
+	tests := []struct {
+		name string
+		r    retention
+		want bool
+	}{
+		{"no rules", retention{}, false},
+		{"id based rules", retention{repliesToIDs: []int64{7}, keepGeotagged: true}, false},
+		{"self quotes", retention{keepSelfQuotes: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.needsFullUser(); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngagementRules(t *testing.T) {
+	tests := []struct {
+		name string
+		r    retention
+		want []string
+	}{
+		{"none", retention{keepGeotagged: true}, nil},
+		{"zero engagement", retention{onlyZeroEngagement: true}, []string{"-only-zero-engagement"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.engagementRules(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// redactedTextLength is how many characters of a tweet's text -sample logs
+// with -redact-text
+const redactedTextLength = 20
+
+// redactText shortens text to its first redactedTextLength characters
+func redactText(text string) string {
+	runes := []rune(text)
+	if len(runes) <= redactedTextLength {
+		return text
+	}
+	return string(runes[:redactedTextLength]) + "…"
+}
+
+// sampledDecision is a decision made about a tweet, kept for reporting
+type sampledDecision struct {
+	id       int64
+	decision string
+	text     string
+}
+
+// reservoir keeps a uniformly random sample of a fixed number of decisions
+// from a stream of unknown length.
+type reservoir struct {
+	size  int
+	seen  int
+	items []sampledDecision
+	rand  *rand.Rand
+}
+
+// newReservoir returns a reservoir that samples up to size decisions
+func newReservoir(size int) *reservoir {
+	return &reservoir{
+		size: size,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// add offers a decision to the sample
+func (r *reservoir) add(dec sampledDecision) {
+	r.seen++
+	if len(r.items) < r.size {
+		r.items = append(r.items, dec)
+		return
+	}
+	if i := r.rand.Intn(r.seen); i < r.size {
+		r.items[i] = dec
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// secretFile is a credential that can be read from a file
+type secretFile struct {
+	name  string
+	path  string
+	value *string
+}
+
+// secretFiles returns the *-file flags along with the credentials they fill in
+func (cfg *config) secretFiles() []secretFile {
+	return []secretFile{
+		{"-consumer-key-file", cfg.consumerKeyFile, &cfg.consumerKey},
+		{"-consumer-secret-file", cfg.consumerSecretFile, &cfg.consumerSecret},
+		{"-oauth-token-file", cfg.oauthTokenFile, &cfg.oauthToken},
+		{"-oauth-token-secret-file", cfg.oauthTokenSecretFile, &cfg.oauthTokenSecret},
+	}
+}
+
+// readSecretFiles fills in credentials from the files named by the *-file
+// flags, the way container platforms mount secrets. A credential given
+// directly takes precedence over its file.
+func (cfg *config) readSecretFiles() error {
+	for _, s := range cfg.secretFiles() {
+		if s.path == "" || *s.value != "" {
+			continue
+		}
+		if err := s.read(); err != nil {
+			return err
+		}
+		cfg.secretsFromFiles = append(cfg.secretsFromFiles, s.name)
+	}
+	return nil
+}
+
+// read sets the credential to the contents of its file
+func (s secretFile) read() error {
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	*s.value = strings.TrimSpace(string(b))
+	if *s.value == "" {
+		return fmt.Errorf("%s %s is empty", s.name, s.path)
+	}
+	return nil
+}
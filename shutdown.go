@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// errInterrupted stops a run that was asked to stop by a signal
+var errInterrupted = errors.New("interrupted")
+
+// shutdown is how a run learns it's been asked to stop. The first interrupt or
+// SIGTERM cancels stop, so the run stops between tweets and saves its
+// progress. A second cancels abort, giving up on what's left to do once the
+// run has stopped, such as posting the summary. Any signal after that is
+// handled as if tprune didn't catch them, ending it at once.
+type shutdown struct {
+	stop, abort context.Context
+	release     func()
+}
+
+// newShutdown starts listening for shutdown signals. release stops listening.
+func newShutdown() shutdown {
+	stop, cancelStop := context.WithCancel(context.Background())
+	abort, cancelAbort := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for _, cancel := range []context.CancelFunc{cancelStop, cancelAbort} {
+			select {
+			case <-signals:
+				cancel()
+			case <-done:
+				return
+			}
+		}
+		signal.Stop(signals)
+	}()
+	return shutdown{
+		stop:  stop,
+		abort: abort,
+		release: func() {
+			signal.Stop(signals)
+			close(done)
+			cancelStop()
+			cancelAbort()
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// runState is how far a run got, so an interrupted run can resume where it
+// left off. The timeline and favorites are tracked separately since one can
+// finish while the other is part way through. Once a run finishes, only when
+// it started is kept, for -incremental.
+type runState struct {
+	TweetMaxID         int64     `json:"tweet_max_id"`
+	FavoriteMaxID      int64     `json:"favorite_max_id"`
+	CompletedTweets    bool      `json:"completed_tweets"`
+	CompletedFavorites bool      `json:"completed_favorites"`
+	LastCompletedAt    time.Time `json:"last_completed_at"`
+}
+
+// loadState reads a state file. A missing file is a fresh start.
+func loadState(path string) (runState, error) {
+	var s runState
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(b, &s)
+	return s, err
+}
+
+// isFresh reports whether the state is that of a run that hasn't started
+func (s runState) isFresh() bool {
+	return s.TweetMaxID == 0 && s.FavoriteMaxID == 0 && !s.CompletedTweets && !s.CompletedFavorites
+}
+
+// save writes the state file, replacing it atomically so an interruption
+// can't leave it half written.
+func (s runState) save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// defaultCheckpointPages is how many pages go between checkpoints unless
+// -checkpoint-interval says otherwise
+const defaultCheckpointPages = 5
+
+// checkpointInterval is how often progress is saved to the state file: after
+// so many pages, or once so much time has passed. As a flag.Value it takes a
+// count of pages ("5") or a duration ("30s").
+type checkpointInterval struct {
+	pages int
+	every time.Duration
+}
+
+func (c *checkpointInterval) Set(v string) error {
+	if n, err := strconv.Atoi(v); err == nil {
+		if n < 1 {
+			return fmt.Errorf("page count must be at least 1")
+		}
+		*c = checkpointInterval{pages: n}
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%q is neither a page count nor a duration", v)
+	}
+	if d <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	*c = checkpointInterval{every: d}
+	return nil
+}
+
+func (c *checkpointInterval) String() string {
+	if c.every > 0 {
+		return c.every.String()
+	}
+	return strconv.Itoa(c.pages)
+}
+
+// due reports whether a checkpoint is due after pages pages and elapsed time
+// since the last one
+func (c checkpointInterval) due(pages int, elapsed time.Duration) bool {
+	if c.every > 0 {
+		return elapsed >= c.every
+	}
+	return pages >= c.pages
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointIntervalSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    checkpointInterval
+		wantErr bool
+	}{
+		{value: "1", want: checkpointInterval{pages: 1}},
+		{value: "12", want: checkpointInterval{pages: 12}},
+		{value: "30s", want: checkpointInterval{every: 30 * time.Second}},
+		{value: "2m", want: checkpointInterval{every: 2 * time.Minute}},
+		{value: "0", wantErr: true},
+		{value: "-3", wantErr: true},
+		{value: "0s", wantErr: true},
+		{value: "-1m", wantErr: true},
+		{value: "often", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		var c checkpointInterval
+		err := c.Set(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c != tt.want {
+			t.Errorf("Set(%q) = %+v, want %+v", tt.value, c, tt.want)
+		}
+	}
+}
+
+func TestCheckpointIntervalDue(t *testing.T) {
+	tests := []struct {
+		interval checkpointInterval
+		pages    int
+		elapsed  time.Duration
+		want     bool
+	}{
+		{checkpointInterval{pages: 5}, 4, time.Hour, false},
+		{checkpointInterval{pages: 5}, 5, 0, true},
+		{checkpointInterval{pages: 5}, 6, 0, true},
+		{checkpointInterval{every: time.Minute}, 100, 59 * time.Second, false},
+		{checkpointInterval{every: time.Minute}, 1, time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := tt.interval.due(tt.pages, tt.elapsed); got != tt.want {
+			t.Errorf("%s: due(%d, %s) = %v, want %v", tt.interval.String(), tt.pages, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestRunStateSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.isFresh() {
+		t.Errorf("missing state file loaded as %+v, want a fresh start", state)
+	}
+
+	want := runState{TweetMaxID: 1234, FavoriteMaxID: 99, CompletedTweets: true}
+	if err := want.save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
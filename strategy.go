@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"go.uber.org/zap"
+)
+
+// removalStrategy removes a tweet the retention policy has decided to delete.
+// Retweets are always un-retweeted and don't go through a strategy.
+type removalStrategy interface {
+	// remove removes the tweet with the given ID, reporting whether it was
+	// removed; tweets that are already gone aren't.
+	remove(logger *zap.Logger, d destroyer, id int64) (bool, error)
+}
+
+// removalStrategies are the strategies selectable with -strategy
+var removalStrategies = map[string]removalStrategy{
+	"delete":       deleteStrategy{},
+	"reply-delete": replyDeleteStrategy{markerFormat: "[deleted %d]"},
+}
+
+// lookupStrategy returns the named removal strategy
+func lookupStrategy(name string) (removalStrategy, error) {
+	s, ok := removalStrategies[name]
+	if !ok {
+		var names []string
+		for n := range removalStrategies {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown strategy %q: expected one of %s", name, strings.Join(names, ", "))
+	}
+	return s, nil
+}
+
+// deleteStrategy deletes the tweet
+type deleteStrategy struct{}
+
+func (deleteStrategy) remove(logger *zap.Logger, d destroyer, id int64) (bool, error) {
+	return d.deleteStatus(logger, id)
+}
+
+// replyDeleteStrategy replies to the tweet with a marker before deleting it, so
+// a thread the tweet was part of shows where something was removed rather than
+// silently skipping it.
+type replyDeleteStrategy struct {
+	// markerFormat is formatted with the tweet's ID. Twitter rejects a
+	// status identical to a recent one, so each marker must differ.
+	markerFormat string
+}
+
+func (s replyDeleteStrategy) remove(logger *zap.Logger, d destroyer, id int64) (bool, error) {
+	_, err := call(func() (*http.Response, error) {
+		_, resp, err := d.client.Statuses.Update(fmt.Sprintf(s.markerFormat, id), &twitter.StatusUpdateParams{
+			InReplyToStatusID: id,
+		})
+		return resp, err
+	})
+	switch {
+	case errors.Is(err, errNotFound):
+		// Nothing left to mark; deleting reports it as already gone
+	case isDuplicateStatus(err):
+		// An earlier run posted the marker but didn't get to delete the
+		// tweet
+		logger.Debug("Marker already posted")
+	case err != nil:
+		unlock := d.lock()
+		ferr := d.recordFailed(failureTweet, id, err)
+		unlock()
+		if ferr != nil {
+			return false, fmt.Errorf("failed to post marker: %v (%w)", err, ferr)
+		}
+		return false, fmt.Errorf("failed to post marker: %w", err)
+	}
+	return d.deleteStatus(logger, id)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// summary is the machine-readable outcome of a run
+type summary struct {
+	RunID            string    `json:"run_id"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	DryRun           bool      `json:"dry_run"`
+	TweetsDeleted    int       `json:"tweets_deleted"`
+	Unretweeted      int       `json:"unretweeted"`
+	TweetsKept       int       `json:"tweets_kept"`
+	FavoritesDeleted int       `json:"favorites_deleted"`
+	FavoritesKept    int       `json:"favorites_kept"`
+	DMsDeleted       int       `json:"dms_deleted"`
+	DMsKept          int       `json:"dms_kept"`
+	AlreadyGone      int       `json:"already_gone"`
+	Errors           int       `json:"errors"`
+	Error            string    `json:"error,omitempty"`
+	// ByYear is only filled in with -report-by-year
+	ByYear []yearCount `json:"by_year,omitempty"`
+}
+
+// newSummary returns the summary of a run that finished now. err is the error
+// the run failed with, if any.
+func newSummary(runID string, startedAt time.Time, dryRun bool, s *stats, err error) summary {
+	sum := summary{
+		RunID:            runID,
+		StartedAt:        startedAt,
+		FinishedAt:       time.Now(),
+		DryRun:           dryRun,
+		TweetsDeleted:    s.tweetsDeleted,
+		Unretweeted:      s.unretweeted,
+		TweetsKept:       s.tweetsKept,
+		FavoritesDeleted: s.favoritesDeleted,
+		FavoritesKept:    s.favoritesKept,
+		DMsDeleted:       s.dmsDeleted,
+		DMsKept:          s.dmsKept,
+		AlreadyGone:      s.alreadyGone,
+		Errors:           s.errors,
+	}
+	if len(s.years) > 0 {
+		sum.ByYear = s.byYear()
+	}
+	if err != nil {
+		sum.Error = err.Error()
+	}
+	return sum
+}
+
+// Summary formats
+const (
+	summaryText = "text"
+	summaryJSON = "json"
+	summaryYAML = "yaml"
+)
+
+// summaryContentTypes are the MIME types of the summary formats
+var summaryContentTypes = map[string]string{
+	summaryText: "text/plain; charset=utf-8",
+	summaryJSON: "application/json",
+	summaryYAML: "application/yaml",
+}
+
+// render writes the summary to w in the given format
+func (s summary) render(format string, w io.Writer) error {
+	switch format {
+	case summaryJSON:
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case summaryYAML:
+		for _, f := range s.fields() {
+			v := f.value
+			if str, ok := v.(string); ok {
+				v = strconv.Quote(str)
+			}
+			if _, err := fmt.Fprintf(w, "%s: %v\n", f.key, v); err != nil {
+				return err
+			}
+		}
+		if len(s.ByYear) > 0 {
+			if _, err := fmt.Fprintln(w, "by_year:"); err != nil {
+				return err
+			}
+		}
+		for _, c := range s.ByYear {
+			if _, err := fmt.Fprintf(w, "  - year: %d\n    deleted: %d\n    kept: %d\n", c.Year, c.Deleted, c.Kept); err != nil {
+				return err
+			}
+		}
+		return nil
+	case summaryText:
+		tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+		for _, f := range s.fields() {
+			fmt.Fprintf(tw, "%s:\t%v\n", f.label, f.value)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(s.ByYear) == 0 {
+			return nil
+		}
+		deleted := "Deleted"
+		if s.DryRun {
+			deleted = "To delete"
+		}
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "\nYear\t%s\tKept\t\n", deleted)
+		for _, c := range s.ByYear {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t\n", c.Year, c.Deleted, c.Kept)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown summary format %q", format)
+}
+
+// summaryField is a summary value along with its key in YAML and its label in
+// text
+type summaryField struct {
+	key, label string
+	value      interface{}
+}
+
+// fields returns the summary's values in display order. The keys match the
+// JSON encoding. The error is left out when there isn't one.
+//
+// Deleting a favorite only unfavorites it, so the labels say "removed" for
+// favorites, and in a dry run say what would happen instead.
+func (s summary) fields() []summaryField {
+	tweets, retweets, favorites, dms := "Tweets deleted", "Un-retweeted", "Favorites removed", "DMs deleted"
+	if s.DryRun {
+		tweets, retweets, favorites, dms = "Tweets to delete", "Retweets to undo", "Favorites to remove", "DMs to delete"
+	}
+	fields := []summaryField{
+		{"run_id", "Run ID", s.RunID},
+		{"started_at", "Started at", s.StartedAt.Format(time.RFC3339)},
+		{"finished_at", "Finished at", s.FinishedAt.Format(time.RFC3339)},
+		{"dry_run", "Dry run", s.DryRun},
+		{"tweets_deleted", tweets, s.TweetsDeleted},
+		{"unretweeted", retweets, s.Unretweeted},
+		{"tweets_kept", "Tweets kept", s.TweetsKept},
+		{"favorites_deleted", favorites, s.FavoritesDeleted},
+		{"favorites_kept", "Favorites kept", s.FavoritesKept},
+		{"dms_deleted", dms, s.DMsDeleted},
+		{"dms_kept", "DMs kept", s.DMsKept},
+		{"already_gone", "Already gone", s.AlreadyGone},
+		{"errors", "Errors", s.Errors},
+	}
+	if s.Error != "" {
+		fields = append(fields, summaryField{"error", "Error", s.Error})
+	}
+	return fields
+}
+
+// writeFile writes the summary to a file in the given format
+func (s summary) writeFile(path, format string) error {
+	var buf bytes.Buffer
+	if err := s.render(format, &buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,44 @@
+window.YTD.tweet.part0 = [ {
+  "tweet" : {
+    "retweeted" : false,
+    "entities" : {
+      "hashtags" : [ {
+        "text" : "keep",
+        "indices" : [ "9", "14" ]
+      } ],
+      "symbols" : [ ],
+      "user_mentions" : [ ],
+      "urls" : [ ]
+    },
+    "display_text_range" : [ "0", "14" ],
+    "favorite_count" : "2",
+    "id_str" : "1102",
+    "truncated" : false,
+    "retweet_count" : "0",
+    "id" : "1102",
+    "created_at" : "Sat Mar 02 18:04:11 +0000 2019",
+    "favorited" : false,
+    "full_text" : "Worth it #keep",
+    "lang" : "en"
+  }
+}, {
+  "tweet" : {
+    "retweeted" : false,
+    "entities" : {
+      "hashtags" : [ ],
+      "symbols" : [ ],
+      "user_mentions" : [ ],
+      "urls" : [ ]
+    },
+    "display_text_range" : [ "0", "12" ],
+    "favorite_count" : "0",
+    "id_str" : "1101",
+    "truncated" : false,
+    "retweet_count" : "0",
+    "id" : "1101",
+    "created_at" : "Fri Mar 01 09:00:00 +0000 2019",
+    "favorited" : false,
+    "full_text" : "Good morning",
+    "lang" : "en"
+  }
+} ]
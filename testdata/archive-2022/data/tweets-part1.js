@@ -0,0 +1,23 @@
+window.YTD.tweets.part1 = [
+  {
+    "tweet" : {
+      "retweeted" : false,
+      "entities" : {
+        "hashtags" : [ ],
+        "symbols" : [ ],
+        "user_mentions" : [ ],
+        "urls" : [ ]
+      },
+      "display_text_range" : [ "0", "13" ],
+      "favorite_count" : "4",
+      "id_str" : "1201",
+      "truncated" : false,
+      "retweet_count" : "1",
+      "id" : "1201",
+      "created_at" : "Sun May 01 12:00:00 +0000 2022",
+      "favorited" : false,
+      "full_text" : "A part1 tweet",
+      "lang" : "en"
+    }
+  }
+]
@@ -0,0 +1,52 @@
+window.YTD.tweets.part0 = [
+  {
+    "tweet" : {
+      "edit_info" : {
+        "initial" : {
+          "editTweetIds" : [ "1204" ],
+          "editableUntil" : "2022-05-04T12:30:00.000Z",
+          "editsRemaining" : "5",
+          "isEditEligible" : true
+        }
+      },
+      "retweeted" : false,
+      "entities" : {
+        "hashtags" : [ ],
+        "symbols" : [ ],
+        "user_mentions" : [ ],
+        "urls" : [ ]
+      },
+      "display_text_range" : [ "0", "17" ],
+      "favorite_count" : "0",
+      "id_str" : "1204",
+      "truncated" : false,
+      "retweet_count" : "0",
+      "id" : "1204",
+      "created_at" : "Wed May 04 12:00:00 +0000 2022",
+      "favorited" : false,
+      "full_text" : "Newer than 3,200?",
+      "lang" : "en"
+    }
+  },
+  {
+    "tweet" : {
+      "retweeted" : false,
+      "entities" : {
+        "hashtags" : [ ],
+        "symbols" : [ ],
+        "user_mentions" : [ ],
+        "urls" : [ ]
+      },
+      "display_text_range" : [ "0", "5" ],
+      "favorite_count" : "0",
+      "id_str" : "1203",
+      "truncated" : false,
+      "retweet_count" : "0",
+      "id" : "1203",
+      "created_at" : "Tue May 03 12:00:00 +0000 2022",
+      "favorited" : false,
+      "full_text" : "Hello",
+      "lang" : "en"
+    }
+  }
+]
@@ -0,0 +1,46 @@
+Grailbird.data.tweets_2013_01 = 
+ [ {
+  "source" : "web",
+  "entities" : {
+    "user_mentions" : [ ],
+    "media" : [ ],
+    "hashtags" : [ ],
+    "urls" : [ ]
+  },
+  "geo" : { },
+  "id_str" : "1002",
+  "text" : "Second tweet",
+  "id" : 1002,
+  "created_at" : "2013-01-20 15:22:05 +0000",
+  "user" : {
+    "name" : "Brett",
+    "screen_name" : "brett",
+    "protected" : false,
+    "id_str" : "42",
+    "profile_image_url_https" : "https://example.com/avatar.png",
+    "id" : 42,
+    "verified" : false
+  }
+}, {
+  "source" : "web",
+  "entities" : {
+    "user_mentions" : [ ],
+    "media" : [ ],
+    "hashtags" : [ ],
+    "urls" : [ ]
+  },
+  "geo" : { },
+  "id_str" : "1001",
+  "text" : "First tweet",
+  "id" : 1001,
+  "created_at" : "2013-01-02 09:00:00 +0000",
+  "user" : {
+    "name" : "Brett",
+    "screen_name" : "brett",
+    "protected" : false,
+    "id_str" : "42",
+    "profile_image_url_https" : "https://example.com/avatar.png",
+    "id" : 42,
+    "verified" : false
+  }
+} ]
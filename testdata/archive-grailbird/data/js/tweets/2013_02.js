@@ -0,0 +1,27 @@
+Grailbird.data.tweets_2013_02 = 
+ [ {
+  "source" : "web",
+  "entities" : {
+    "user_mentions" : [ ],
+    "media" : [ ],
+    "hashtags" : [ {
+      "text" : "keep",
+      "indices" : [ 14, 19 ]
+    } ],
+    "urls" : [ ]
+  },
+  "geo" : { },
+  "id_str" : "1003",
+  "text" : "Worth keeping #keep",
+  "id" : 1003,
+  "created_at" : "2013-02-14 12:00:00 +0000",
+  "user" : {
+    "name" : "Brett",
+    "screen_name" : "brett",
+    "protected" : false,
+    "id_str" : "42",
+    "profile_image_url_https" : "https://example.com/avatar.png",
+    "id" : 42,
+    "verified" : false
+  }
+} ]
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/dghubble/oauth1"
+)
+
+// tokenSource supplies the OAuth credentials requests are signed with
+type tokenSource interface {
+	// credentials returns the current credentials
+	credentials() (*oauth1.Config, *oauth1.Token)
+	// refresh replaces the credentials after Twitter rejected them,
+	// reporting whether they changed
+	refresh() (bool, error)
+}
+
+// newTokenSource returns a source for the configured credentials. Credentials
+// read from *-file flags are read again on refresh, so secrets rotated during
+// a long run are picked up; credentials given directly never change.
+func newTokenSource(cfg config) tokenSource {
+	if len(cfg.secretsFromFiles) > 0 {
+		return &fileTokenSource{cfg: cfg}
+	}
+	return staticTokenSource{cfg: cfg}
+}
+
+// oauthCredentials returns the OAuth config and token for cfg's credentials
+func oauthCredentials(cfg config) (*oauth1.Config, *oauth1.Token) {
+	return oauth1.NewConfig(cfg.consumerKey, cfg.consumerSecret), oauth1.NewToken(cfg.oauthToken, cfg.oauthTokenSecret)
+}
+
+// staticTokenSource is a tokenSource for credentials that can't be refreshed
+type staticTokenSource struct {
+	cfg config
+}
+
+func (s staticTokenSource) credentials() (*oauth1.Config, *oauth1.Token) {
+	return oauthCredentials(s.cfg)
+}
+
+func (s staticTokenSource) refresh() (bool, error) {
+	return false, nil
+}
+
+// fileTokenSource is a tokenSource that reads credentials from the files they
+// came from again on refresh
+type fileTokenSource struct {
+	mu  sync.Mutex
+	cfg config
+}
+
+func (s *fileTokenSource) credentials() (*oauth1.Config, *oauth1.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return oauthCredentials(s.cfg)
+}
+
+func (s *fileTokenSource) refresh() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.cfg
+	for _, f := range next.secretFiles() {
+		if !containsString(next.secretsFromFiles, f.name) {
+			continue
+		}
+		if err := f.read(); err != nil {
+			return false, err
+		}
+	}
+	changed := next.consumerKey != s.cfg.consumerKey ||
+		next.consumerSecret != s.cfg.consumerSecret ||
+		next.oauthToken != s.cfg.oauthToken ||
+		next.oauthTokenSecret != s.cfg.oauthTokenSecret
+	s.cfg = next
+	return changed, nil
+}
+
+// refreshTransport signs requests with the credentials from a tokenSource.
+// When Twitter rejects them with a 401, it refreshes the credentials and, if
+// they changed, retries the request once with the new ones.
+type refreshTransport struct {
+	ctx    context.Context
+	source tokenSource
+
+	mu     sync.Mutex
+	signer http.RoundTripper
+}
+
+// newRefreshTransport returns a refreshTransport. Signed requests are sent
+// with the transport of the client in ctx under oauth1.HTTPClient.
+func newRefreshTransport(ctx context.Context, source tokenSource) *refreshTransport {
+	t := &refreshTransport{ctx: ctx, source: source}
+	t.signer = t.newSigner()
+	return t
+}
+
+// newSigner returns a transport signing requests with the current credentials
+func (t *refreshTransport) newSigner() http.RoundTripper {
+	config, token := t.source.credentials()
+	return config.Client(t.ctx, token).Transport
+}
+
+func (t *refreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	signer := t.signer
+	t.mu.Unlock()
+
+	resp, err := signer.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// A body can only be sent again if it can be recreated
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	// Another request may have refreshed the credentials already
+	if t.signer == signer {
+		changed, err := t.source.refresh()
+		if err != nil {
+			t.mu.Unlock()
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to refresh credentials: %w", err)
+		}
+		if !changed {
+			t.mu.Unlock()
+			return resp, nil
+		}
+		t.signer = t.newSigner()
+	}
+	signer = t.signer
+	t.mu.Unlock()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+	return signer.RoundTrip(retry)
+}
+
+// containsString reports whether ss contains s
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
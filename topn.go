@@ -0,0 +1,144 @@
+package main
+
+import (
+	"container/heap"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// engagedTweet is a tweet and how many times it's been favorited
+type engagedTweet struct {
+	id        int64
+	favorites int
+}
+
+// engagementHeap is a min-heap of tweets by favorites, so the least favorited
+// of the top tweets so far is the one to drop. Among tweets with the same
+// count the newest goes first, so older tweets win ties.
+type engagementHeap []engagedTweet
+
+func (h engagementHeap) Len() int { return len(h) }
+func (h engagementHeap) Less(i, j int) bool {
+	if h[i].favorites != h[j].favorites {
+		return h[i].favorites < h[j].favorites
+	}
+	return h[i].id > h[j].id
+}
+func (h engagementHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *engagementHeap) Push(x interface{}) { *h = append(*h, x.(engagedTweet)) }
+func (h *engagementHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// topTweets selects the n most favorited tweets old enough to be deleted.
+// Only the n best so far are held, so memory stays proportional to n however
+// long the timeline is.
+type topTweets struct {
+	n    int
+	r    retention
+	now  time.Time
+	heap engagementHeap
+}
+
+// newTopTweets returns an empty topTweets keeping n tweets older than the max
+// age r applies to them
+func newTopTweets(n int, r retention, now time.Time) *topTweets {
+	return &topTweets{n: n, r: r, now: now}
+}
+
+// eligible reports whether a tweet can take a place. Retweets can't since
+// their counts are the original tweet's, and neither can tweets young enough
+// to be kept anyway. Retweets are told by their "RT @" text, which is all an
+// archive has to go on.
+func (top *topTweets) eligible(t tweetRecord) (bool, error) {
+	if strings.HasPrefix(t.text(), "RT @") {
+		return false, nil
+	}
+	createdAt, err := t.createdAt()
+	if err != nil {
+		return false, err
+	}
+	return olderThan(createdAt, top.now, top.r.maxAgeFor(t)), nil
+}
+
+// add considers a tweet, by the favorite count it has
+func (top *topTweets) add(t tweetRecord) error {
+	ok, err := top.eligible(t)
+	if err != nil || !ok {
+		return err
+	}
+	heap.Push(&top.heap, engagedTweet{id: t.tweetID(), favorites: t.favoriteCount()})
+	if top.heap.Len() > top.n {
+		heap.Pop(&top.heap)
+	}
+	return nil
+}
+
+// ids returns the IDs of the selected tweets
+func (top *topTweets) ids() []int64 {
+	ids := make([]int64, len(top.heap))
+	for i, t := range top.heap {
+		ids[i] = t.id
+	}
+	return ids
+}
+
+// topTimelineTweets pages through the whole timeline and returns the IDs of
+// its n most favorited tweets old enough for r to delete. It's a pass of its
+// own before any deleting, so it costs as many timeline requests again as the
+// run.
+func topTimelineTweets(client *twitter.Client, username string, pageSize, n int, r retention, now time.Time) ([]int64, error) {
+	var (
+		top     = newTopTweets(n, r, now)
+		fetcher = newTweetFetcher(client, username, pageSize, true)
+	)
+	for fetcher.fetch() {
+		for _, t := range fetcher.tweets {
+			if err := top.add(apiTweet{t}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if fetcher.err != nil {
+		return nil, fetcher.err
+	}
+	return top.ids(), nil
+}
+
+// topArchivedTweets returns the IDs of the n most favorited tweets in an
+// archive old enough for r to delete. An archive's counts are as of its export,
+// and Grailbird archives have none, so the eligible tweets are looked up for
+// their current counts, a statuses/lookup request per 100. Tweets that are gone
+// are left out.
+func topArchivedTweets(client *twitter.Client, archived []archiveTweet, n int, r retention, now time.Time) ([]int64, error) {
+	top := newTopTweets(n, r, now)
+	var candidates []archiveTweet
+	for _, at := range archived {
+		ok, err := top.eligible(at)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, at)
+		}
+	}
+	existing, err := lookupExisting(client, candidates, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, at := range candidates {
+		t, ok := existing[at.tweetID()]
+		if !ok {
+			continue
+		}
+		if err := top.add(apiTweet{t}); err != nil {
+			return nil, err
+		}
+	}
+	return top.ids(), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// favoritedAt returns a tweet with the ID and creation time, favorited n times
+func favoritedAt(id int64, t time.Time, n int) twitter.Tweet {
+	tweet := tweetAt(id, t)
+	tweet.FavoriteCount = n
+	return tweet
+}
+
+func TestTopTweets(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-40 * day)
+	retweet := favoritedAt(9, old, 100)
+	retweet.Text = "RT @someone: hi"
+	retweet.RetweetedStatus = &twitter.Tweet{ID: 1}
+	tests := []struct {
+		name   string
+		n      int
+		tweets []twitter.Tweet
+		want   []int64
+	}{
+		{
+			name:   "most favorited",
+			n:      2,
+			tweets: []twitter.Tweet{favoritedAt(4, old, 5), favoritedAt(3, old, 1), favoritedAt(2, old, 9), favoritedAt(1, old, 3)},
+			want:   []int64{2, 4},
+		},
+		{
+			name:   "fewer than n",
+			n:      5,
+			tweets: []twitter.Tweet{favoritedAt(2, old, 1), favoritedAt(1, old, 0)},
+			want:   []int64{1, 2},
+		},
+		{
+			name:   "older wins ties",
+			n:      1,
+			tweets: []twitter.Tweet{favoritedAt(3, old, 2), favoritedAt(2, old, 2), favoritedAt(1, old, 2)},
+			want:   []int64{1},
+		},
+		{
+			name:   "skips tweets young enough to keep",
+			n:      1,
+			tweets: []twitter.Tweet{favoritedAt(2, now.Add(-day), 50), favoritedAt(1, old, 1)},
+			want:   []int64{1},
+		},
+		{
+			name:   "skips retweets",
+			n:      1,
+			tweets: []twitter.Tweet{retweet, favoritedAt(1, old, 1)},
+			want:   []int64{1},
+		},
+		{
+			name: "skips manual retweets",
+			n:    1,
+			tweets: []twitter.Tweet{
+				{ID: 2, CreatedAt: old.UTC().Format(time.RubyDate), Text: "RT @someone: hi", FavoriteCount: 50},
+				favoritedAt(1, old, 1),
+			},
+			want: []int64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := newTopTweets(tt.n, retention{maxAge: 30 * day}, now)
+			for _, tweet := range tt.tweets {
+				if err := top.add(apiTweet{tweet}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := top.ids()
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneKeepTopN(t *testing.T) {
+	tests := []struct {
+		name          string
+		n             string
+		limited       int
+		wantDestroyed []int64
+	}{
+		{"one", "1", 0, []int64{104, 102, 101}},
+		{"two", "2", 0, []int64{104, 102}},
+		{"rate limited", "1", 1, []int64{104, 102, 101}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestTimeline(t)
+			f.tweets[3].FavoriteCount = 10 // 103
+			f.tweets[5].FavoriteCount = 5  // 101
+			// The first request is the pass ranking the tweets
+			f.rateLimit("/1.1/statuses/user_timeline.json", tt.limited)
+			err := runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-keep-top-n="+tt.n, "-page-size=2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+			if f.limited["/1.1/statuses/user_timeline.json"] > 0 {
+				t.Error("the timeline was never rate limited")
+			}
+			// Four pages each for ranking the tweets and for the run
+			if n := len(f.timelineCounts); n != 8 {
+				t.Errorf("fetched %d timeline pages, want 8", n)
+			}
+			for _, count := range f.timelineCounts {
+				if count != 2 {
+					t.Errorf("fetched pages of %v, want all of -page-size", f.timelineCounts)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPruneKeepTopNArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		// favorited holds the current favorite counts, which a Grailbird
+		// archive doesn't have
+		favorited     map[int64]int
+		gone          []int64
+		wantDestroyed []int64
+	}{
+		// Without current counts, every tweet would tie at zero and the
+		// oldest, 1001, would win
+		{"current counts", map[int64]int{1002: 9, 1001: 1}, nil, []int64{106, 105, 1003, 1001}},
+		{"gone tweets left out", map[int64]int{1002: 9, 1001: 1}, []int64{1002}, []int64{106, 105, 1003}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			f := newFakeTwitter(t, testAccount)
+			f.tweets = []twitter.Tweet{
+				tweetAt(106, now.Add(-40*day)),
+				tweetAt(105, now.Add(-50*day)),
+			}
+			archived, err := readArchive("testdata/archive-grailbird")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, at := range archived {
+				tweet, err := at.toTweet()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if containsID(tt.gone, tweet.ID) {
+					continue
+				}
+				tweet.FavoriteCount = tt.favorited[tweet.ID]
+				f.tweets = append(f.tweets, tweet)
+			}
+			f.timelineLimit = 2
+
+			err = runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false",
+				"-keep-top-n=1", "-archive=testdata/archive-grailbird", "-verify-before-delete")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.destroyed, tt.wantDestroyed) {
+				t.Errorf("destroyed %v, want %v", f.destroyed, tt.wantDestroyed)
+			}
+		})
+	}
+}
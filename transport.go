@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// baseURLTransport sends requests for the Twitter API to another base URL, such
+// as a test server or an API gateway. The API version stays in the path, so
+// https://api.twitter.com/1.1/statuses/destroy/1.json becomes
+// <base>/1.1/statuses/destroy/1.json. Requests are signed before they're
+// redirected, so a gateway must forward them to Twitter unchanged.
+type baseURLTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+// twitterAPIHost is the host go-twitter sends requests to
+const twitterAPIHost = "api.twitter.com"
+
+func (t baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != twitterAPIHost {
+		return t.next.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request they're given
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.base.Scheme
+	req.URL.Host = t.base.Host
+	req.URL.Path = strings.TrimSuffix(t.base.Path, "/") + req.URL.Path
+	req.URL.RawPath = ""
+	req.Host = ""
+	return t.next.RoundTrip(req)
+}
+
+// userAgentTransport sets the User-Agent header on every request
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
+
+// rateLimitTransport tracks each endpoint's rate-limit window from response
+// headers. Once a response shows an endpoint's window is used up, further
+// requests to it fail with a rateLimitedError until the window resets, without
+// being sent. Waiting is left to call, since the HTTP client's timeout covers
+// the time spent in the transport.
+//
+// This is also what keeps fetching and deleting from starving each other. A
+// fetcher that uses up the timeline's window still gets its page, and only its
+// request for the next page waits, so the page is deleted in the meantime.
+// Fetching can't run ahead of deleting and build up a backlog either: once
+// -in-flight deletions are pending, nothing more is fetched until one
+// finishes.
+type rateLimitTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	resets map[string]time.Time
+}
+
+// newRateLimitTransport returns a rateLimitTransport wrapping next
+func newRateLimitTransport(next http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		next:   next,
+		resets: map[string]time.Time{},
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointKey(req.URL)
+	t.mu.Lock()
+	reset, limited := t.resets[endpoint]
+	if limited && !time.Now().Before(reset) {
+		delete(t.resets, endpoint)
+		limited = false
+	}
+	t.mu.Unlock()
+	if limited {
+		return nil, rateLimitedError{endpoint: endpoint, until: reset}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	remaining, rerr := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining"))
+	exhausted := rerr == nil && remaining <= 0
+	if exhausted || resp.StatusCode == http.StatusTooManyRequests {
+		if reset, err := rateLimitReset(resp.Header); err == nil {
+			t.mu.Lock()
+			t.resets[endpoint] = reset
+			t.mu.Unlock()
+		}
+	}
+	return resp, nil
+}
+
+// rateLimitedError is returned by rateLimitTransport for a request to an
+// endpoint whose window is used up
+type rateLimitedError struct {
+	endpoint string
+	until    time.Time
+}
+
+func (e rateLimitedError) Error() string {
+	return fmt.Sprintf("%s is rate limited until %s", e.endpoint, e.until.Format(time.RFC3339))
+}
+
+// rateLimitReset returns when the rate-limit window in the response headers
+// resets. The reset header is a Unix timestamp.
+func rateLimitReset(header http.Header) (time.Time, error) {
+	reset, err := strconv.ParseInt(header.Get("X-Rate-Limit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(reset, 0), nil
+}
+
+// endpointKey identifies the endpoint a request is for, with the IDs in paths
+// like /1.1/statuses/destroy/123.json replaced so every tweet shares a key.
+// The first segment is the API version, which is never an ID, though the v2
+// API's looks like one.
+func endpointKey(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if i < 2 {
+			continue
+		}
+		id := strings.TrimSuffix(s, ".json")
+		if id != "" && strings.Trim(id, "0123456789") == "" {
+			segments[i] = ":id" + strings.TrimPrefix(s, id)
+		}
+	}
+	return u.Host + strings.Join(segments, "/")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEndpointKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.twitter.com/1.1/statuses/user_timeline.json?count=200", "api.twitter.com/1.1/statuses/user_timeline.json"},
+		{"https://api.twitter.com/1.1/statuses/destroy/123.json", "api.twitter.com/1.1/statuses/destroy/:id.json"},
+		{"https://api.twitter.com/1.1/statuses/destroy/456.json", "api.twitter.com/1.1/statuses/destroy/:id.json"},
+		{"https://api.twitter.com/1.1/favorites/destroy.json", "api.twitter.com/1.1/favorites/destroy.json"},
+		{"https://api.twitter.com/2/users/42", "api.twitter.com/2/users/:id"},
+		{"https://api.twitter.com/2/tweets/123", "api.twitter.com/2/tweets/:id"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := endpointKey(u); got != tt.want {
+			t.Errorf("endpointKey(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling itself
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	var sent []string
+	transport := newRateLimitTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req.URL.Path)
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		if req.URL.Path == "/1.1/statuses/user_timeline.json" {
+			// The window is used up by this request
+			resp.Header.Set("X-Rate-Limit-Remaining", "0")
+			resp.Header.Set("X-Rate-Limit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		}
+		return resp, nil
+	}))
+
+	tests := []struct {
+		url     string
+		limited bool
+	}{
+		// The request using up the window still gets its page
+		{"https://api.twitter.com/1.1/statuses/user_timeline.json", false},
+		// Deleting isn't held up by the timeline's window
+		{"https://api.twitter.com/1.1/statuses/destroy/1.json", false},
+		{"https://api.twitter.com/1.1/statuses/destroy/2.json", false},
+		// Only the timeline's next page waits
+		{"https://api.twitter.com/1.1/statuses/user_timeline.json?max_id=1", true},
+		{"https://api.twitter.com/1.1/favorites/list.json", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = transport.RoundTrip(req)
+		var limited rateLimitedError
+		if got := errors.As(err, &limited); got != tt.limited {
+			t.Errorf("%s: rate limited = %v (%v), want %v", tt.url, got, err, tt.limited)
+			continue
+		}
+		if tt.limited && !limited.until.Equal(reset) {
+			t.Errorf("%s: limited until %s, want %s", tt.url, limited.until, reset)
+		}
+	}
+	if len(sent) != 4 {
+		t.Errorf("sent %v, want all but the rate-limited request", sent)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, "tprune/" + version},
+		{"custom", []string{"-user-agent=my-gateway/1.0"}, "my-gateway/1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.UserAgent()
+			}))
+			defer server.Close()
+
+			var (
+				cfg        config
+				configPath string
+			)
+			flagset := flag.NewFlagSet("tprune", flag.ContinueOnError)
+			cfg.registerCommonFlags(flagset, &configPath)
+			if err := flagset.Parse(append(tt.args, "-api-base-url="+server.URL)); err != nil {
+				t.Fatal(err)
+			}
+			client, err := newHTTPClient(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get("https://api.twitter.com/1.1/account/verify_credentials.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got != tt.want {
+				t.Errorf("sent User-Agent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// maxUserLookup is the most users a single users/lookup request resolves
+const maxUserLookup = 100
+
+// maxVerifiedCache bounds how many users verifiedCache remembers. Once full,
+// the least recently used are forgotten first.
+const maxVerifiedCache = 10000
+
+// verifiedCache resolves whether users are verified with users/lookup,
+// remembering the answers. Users that can't be looked up, such as suspended
+// or deleted accounts, count as unverified.
+type verifiedCache struct {
+	client *twitter.Client
+	// users maps user IDs to their elements in recent, which holds
+	// verifiedUsers, most recently used first
+	users  map[int64]*list.Element
+	recent *list.List
+}
+
+// verifiedUser is whether a user is verified
+type verifiedUser struct {
+	id       int64
+	verified bool
+}
+
+// newVerifiedCache returns an empty verifiedCache
+func newVerifiedCache(client *twitter.Client) *verifiedCache {
+	return &verifiedCache{
+		client: client,
+		users:  map[int64]*list.Element{},
+		recent: list.New(),
+	}
+}
+
+// isVerified reports whether the user is verified, looking them up if they
+// aren't cached
+func (c *verifiedCache) isVerified(userID int64) (bool, error) {
+	if err := c.prefetch([]int64{userID}); err != nil {
+		return false, err
+	}
+	if e, ok := c.users[userID]; ok {
+		c.recent.MoveToFront(e)
+		return e.Value.(verifiedUser).verified, nil
+	}
+	return false, nil
+}
+
+// prefetch looks up the users that aren't cached yet, up to maxUserLookup per
+// request, so a page of tweets costs a single request instead of one per reply.
+func (c *verifiedCache) prefetch(userIDs []int64) error {
+	var missing []int64
+	for _, id := range userIDs {
+		if _, ok := c.users[id]; ok || id == 0 || containsID(missing, id) {
+			continue
+		}
+		missing = append(missing, id)
+	}
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxUserLookup {
+			batch = batch[:maxUserLookup]
+		}
+		missing = missing[len(batch):]
+
+		var users []twitter.User
+		_, err := call(func() (resp *http.Response, err error) {
+			users, resp, err = c.client.Users.Lookup(&twitter.UserLookupParams{UserID: batch})
+			return resp, err
+		})
+		// users/lookup responds 404 when none of the users exist
+		if err != nil && !errors.Is(err, errNotFound) {
+			return fmt.Errorf("failed to look up users: %w", err)
+		}
+		verified := map[int64]bool{}
+		for _, u := range users {
+			verified[u.ID] = u.Verified
+		}
+		for _, id := range batch {
+			c.add(id, verified[id])
+		}
+	}
+	return nil
+}
+
+// add caches whether a user is verified, forgetting the least recently used
+// user once the cache is full
+func (c *verifiedCache) add(userID int64, verified bool) {
+	c.users[userID] = c.recent.PushFront(verifiedUser{id: userID, verified: verified})
+	if c.recent.Len() > maxVerifiedCache {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.users, oldest.Value.(verifiedUser).id)
+	}
+}
+
+// replyToUserIDs returns the IDs of the users the tweets reply to, leaving out
+// tweets young enough that r keeps them without asking whether the user is
+// verified
+func replyToUserIDs(r retention, now time.Time, tweets []twitter.Tweet) ([]int64, error) {
+	var ids []int64
+	for _, t := range tweets {
+		userID, _ := apiTweet{t}.replyTo()
+		if userID == 0 {
+			continue
+		}
+		createdAt, err := t.CreatedAtTime()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tweet creation time: %w", err)
+		}
+		if olderThan(createdAt, now, r.maxAgeFor(apiTweet{t})) {
+			ids = append(ids, userID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func reply(id, userID int64, createdAt time.Time) twitter.Tweet {
+	t := tweetAt(id, createdAt)
+	t.InReplyToUserID = userID
+	return t
+}
+
+func TestReplyToUserIDs(t *testing.T) {
+	now := time.Now()
+	photo := reply(4, 4, now.Add(-40*day))
+	photo.Entities = &twitter.Entities{Media: []twitter.MediaEntity{{Type: "photo"}}}
+	tests := []struct {
+		name   string
+		r      retention
+		tweets []twitter.Tweet
+		want   []int64
+	}{
+		{
+			"old replies",
+			retention{maxAge: 30 * day},
+			[]twitter.Tweet{reply(1, 1, now.Add(-40*day)), tweetAt(2, now.Add(-40*day)), reply(3, 3, now.Add(-50*day))},
+			[]int64{1, 3},
+		},
+		{
+			"young replies",
+			retention{maxAge: 30 * day},
+			[]twitter.Tweet{reply(1, 1, now.Add(-time.Hour)), reply(3, 3, now.Add(-50*day))},
+			[]int64{3},
+		},
+		{
+			"media kept longer",
+			retention{maxAge: 30 * day, mediaMaxAge: 60 * day},
+			[]twitter.Tweet{photo, reply(5, 5, now.Add(-40*day))},
+			[]int64{5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replyToUserIDs(tt.r, now, tt.tweets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifiedCacheEviction(t *testing.T) {
+	c := newVerifiedCache(nil)
+	for id := int64(1); id <= maxVerifiedCache; id++ {
+		c.add(id, id == 1)
+	}
+	// Using the oldest user makes the second oldest the least recently used
+	if verified, err := c.isVerified(1); err != nil || !verified {
+		t.Fatalf("got %t, %v, want true", verified, err)
+	}
+	c.add(maxVerifiedCache+1, false)
+
+	if len(c.users) != maxVerifiedCache || c.recent.Len() != maxVerifiedCache {
+		t.Errorf("cached %d and %d users, want %d", len(c.users), c.recent.Len(), maxVerifiedCache)
+	}
+	for _, tt := range []struct {
+		id     int64
+		cached bool
+	}{{1, true}, {2, false}, {3, true}, {maxVerifiedCache + 1, true}} {
+		if _, ok := c.users[tt.id]; ok != tt.cached {
+			t.Errorf("user %d cached %t, want %t", tt.id, ok, tt.cached)
+		}
+	}
+}
+
+func TestPruneKeepVerifiedReplies(t *testing.T) {
+	now := time.Now()
+	f := newFakeTwitter(t, testAccount)
+	f.users = map[int64]twitter.User{
+		8: {ID: 8, Verified: true},
+		9: {ID: 9},
+	}
+	// 106 is too young to delete, so whether 7 is verified doesn't matter
+	f.tweets = []twitter.Tweet{
+		reply(106, 7, now.Add(-time.Hour)),
+		reply(105, 8, now.Add(-40*day)),
+	}
+	archived, err := readArchive("testdata/archive-2022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range archived {
+		tweet, err := at.toTweet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tweet.ID {
+		case 1204:
+			tweet.InReplyToUserID = 8
+		case 1203:
+			tweet.InReplyToUserID = 9
+		case 1201:
+			// 10 can't be found, so doesn't count as verified
+			tweet.InReplyToUserID = 10
+		}
+		f.tweets = append(f.tweets, tweet)
+	}
+	f.timelineLimit = 2
+
+	if err := runPrune(t, f, "-max-age=30d", "-tweets-only", "-keep-pinned=false", "-keep-verified-replies",
+		"-archive=testdata/archive-2022", "-verify-before-delete"); err != nil {
+		t.Fatal(err)
+	}
+	// A request for the timeline's page and one for the archive's batch,
+	// which only needs the users not seen yet
+	if want := []string{"8", "9,10"}; !reflect.DeepEqual(f.userLookups, want) {
+		t.Errorf("looked up users %q, want %q", f.userLookups, want)
+	}
+	if want := []int64{1203, 1201}; !reflect.DeepEqual(f.destroyed, want) {
+		t.Errorf("destroyed %v, want %v", f.destroyed, want)
+	}
+}
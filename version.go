@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version and commit identify the build. Release builds set them with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234".
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+func init() {
+	// Builds with go install carry the module version instead
+	if version != "dev" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+}
+
+// versionString describes the build for -version
+func versionString() string {
+	return fmt.Sprintf("tprune %s (commit %s, %s)", version, commit, runtime.Version())
+}
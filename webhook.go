@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the request body, as
+// "sha256=<hex digest>", when a webhook secret is configured.
+const webhookSignatureHeader = "X-Tprune-Signature"
+
+// postWebhook POSTs the summary to the configured webhook URL in the
+// configured summary format
+func postWebhook(ctx context.Context, cfg config, sum summary) error {
+	var buf bytes.Buffer
+	if err := sum.render(cfg.summaryFormat(), &buf); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", summaryContentTypes[cfg.summaryFormat()])
+	if cfg.webhookSecret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(cfg.webhookSecret, body))
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: transport, Timeout: cfg.httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the hex-encoded HMAC-SHA256 of body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// errOutsideWindow stops a run that reaches the end of its active window
+var errOutsideWindow = errors.New("outside active window")
+
+// activeWindow is a daily window of time, such as 02:00-05:00, that deletions
+// are allowed in. A window whose end is before its start runs past midnight.
+type activeWindow struct {
+	// start and end are minutes after midnight
+	start, end int
+	loc        *time.Location
+}
+
+// parseActiveWindow parses a window like "02:00-05:00" in loc
+func parseActiveWindow(v string, loc *time.Location) (*activeWindow, error) {
+	bounds := strings.SplitN(v, "-", 2)
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid active window %q: expected HH:MM-HH:MM", v)
+	}
+	var minutes [2]int
+	for i, b := range bounds {
+		t, err := time.Parse("15:04", strings.TrimSpace(b))
+		if err != nil {
+			return nil, fmt.Errorf("invalid active window %q: %q is not a time of day", v, b)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	if minutes[0] == minutes[1] {
+		return nil, fmt.Errorf("invalid active window %q: start and end are the same", v)
+	}
+	return &activeWindow{start: minutes[0], end: minutes[1], loc: loc}, nil
+}
+
+// contains reports whether t falls in the window
+func (w *activeWindow) contains(t time.Time) bool {
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}